@@ -1,10 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/clients/bot"
+	"github.com/grigory222/scraptor/internal/clients/feed"
+	"github.com/grigory222/scraptor/internal/clients/github"
+	"github.com/grigory222/scraptor/internal/clients/ratelimit"
+	"github.com/grigory222/scraptor/internal/clients/reddit"
+	"github.com/grigory222/scraptor/internal/clients/stackoverflow"
+	"github.com/grigory222/scraptor/internal/clients/telegram"
+	"github.com/grigory222/scraptor/internal/clients/vk"
+	"github.com/grigory222/scraptor/internal/clients/webpage"
 	"github.com/grigory222/scraptor/internal/config"
 	"github.com/grigory222/scraptor/internal/http-server/handlers"
+	"github.com/grigory222/scraptor/internal/migrate"
+	"github.com/grigory222/scraptor/internal/outbox"
+	"github.com/grigory222/scraptor/internal/queue"
 	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/grigory222/scraptor/internal/scheduler"
+	"github.com/grigory222/scraptor/internal/secrets"
 	"github.com/grigory222/scraptor/internal/service"
+	"github.com/grigory222/scraptor/internal/sources"
+	"github.com/grigory222/scraptor/migrations"
 	"github.com/labstack/echo/v4"
 
 	slogpretty "github.com/grigory222/scraptor/internal/logger"
@@ -15,13 +42,198 @@ func main() {
 
 	log := slogpretty.NewLogger()
 
-	db := repository.NewPostgres(cfg.DB, log)
-	svc := service.NewService(db, log)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	keys, err := newKeyring(cfg.Tokens)
+	if err != nil {
+		log.Error("Can't set up token encryption", "err", err)
+		os.Exit(1)
+	}
+	if keys == nil {
+		log.Warn("TOKENS_KEY is not set, access tokens can't be added")
+	}
+
+	db := repository.NewPostgres(cfg.DB, keys, log)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:], log); err != nil {
+			log.Error("Migration failed", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.DB.AutoMigrate {
+		if err := runMigrate(db, []string{"up"}, log); err != nil {
+			log.Error("Migration failed", "err", err)
+			os.Exit(1)
+		}
+	}
+	if keys != nil {
+		n, err := db.ReencryptTokens(ctx)
+		if err != nil {
+			log.Error("Can't re-encrypt tokens", "err", err)
+			os.Exit(1)
+		}
+		if n > 0 {
+			log.Info("Tokens re-encrypted with current key", "count", n)
+		}
+	}
+
+	// ссылки задают пользователи, поэтому источники ходят только на публичные адреса
+	transport := http.RoundTripper(clients.NewPublicTransport())
+	if cfg.AllowPrivateHosts {
+		log.Warn("ALLOW_PRIVATE_HOSTS is set, links may point to the internal network")
+		transport = http.DefaultTransport
+	}
+	limiter := newRateLimiter(cfg.RateLimit, transport)
+	httpClient := &http.Client{Timeout: 10 * time.Second, Transport: limiter}
+	registry := sources.NewRegistry(
+		github.NewClient(cfg.GitHub.BaseURL, httpClient, db),
+		stackoverflow.NewClient(cfg.StackOverflow.BaseURL, cfg.StackOverflow.Key, httpClient),
+		reddit.NewClient(cfg.Reddit.BaseURL, cfg.Reddit.OAuthURL, cfg.Reddit.UserAgent, httpClient, db),
+		vk.NewClient(cfg.VK.BaseURL, cfg.VK.Version, httpClient, db),
+		feed.NewClient(httpClient),
+		webpage.NewClient(httpClient),
+	)
+	if !cfg.AllowPrivateHosts {
+		registry.SetHostCheck(clients.CheckPublicHost)
+	}
+
+	// ссылки, сохранённые до канонизации, сливаются с такими же каноническими
+	if n, err := db.CanonicalizeLinks(ctx, registry.Canonicalize); err != nil {
+		log.Error("Can't canonicalize stored links", "err", err)
+	} else if n > 0 {
+		log.Info("Stored links canonicalized", "count", n)
+	}
+
+	svc := service.NewService(db, registry, log)
+
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		log.Error("Can't set up notifications", "err", err)
+		os.Exit(1)
+	}
+
+	sched := scheduler.NewScheduler(db, registry, cfg.Scheduler, log)
+	sched.Start(ctx)
+
+	dispatcher := outbox.NewDispatcher(db, notifier, cfg.Outbox, log)
+	dispatcher.Start(ctx)
 
 	e := echo.New()
 
-	handlers.RegisterMiddlewares(e)
+	handlers.RegisterMiddlewares(e, cfg.RequestTimeout)
 	handlers.RegisterRoutes(e, svc)
+	handlers.RegisterAdminRoutes(e, svc, cfg.AdminToken)
+	if cfg.AdminToken == "" {
+		log.Warn("ADMIN_TOKEN is not set, admin and debug routes are disabled")
+	}
+	handlers.RegisterDebugRoutes(e, limiter, cfg.AdminToken)
+
+	go func() {
+		if err := e.Start(cfg.ServerAddr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Server stopped", "err", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	log.Info("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Error("Server shutdown failed", "err", err)
+	}
+	sched.Stop()
+	dispatcher.Stop()
+}
+
+// runMigrate выполняет команду migrate up|down|status
+func runMigrate(db *repository.Postgres, args []string, log *slog.Logger) error {
+	m, err := migrate.New(db.DB, migrations.FS, log)
+	if err != nil {
+		return err
+	}
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		n, err := m.Up()
+		if err != nil {
+			return err
+		}
+		log.Info("Schema is up to date", "applied", n)
+	case "down":
+		ok, err := m.Down()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Info("No migrations to roll back")
+		}
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", cmd)
+	}
+	return nil
+}
+
+// newKeyring создаёт ключи шифрования токенов; nil, если ключ не задан
+func newKeyring(cfg config.TokensConfig) (*secrets.Keyring, error) {
+	if cfg.Key == "" {
+		return nil, nil
+	}
+	return secrets.NewKeyring(cfg.Key, cfg.OldKeys...)
+}
+
+// newNotifier выбирает способ доставки уведомлений
+func newNotifier(cfg *config.Config) (outbox.Notifier, error) {
+	switch cfg.Delivery {
+	case "bot":
+		return bot.NewClient(cfg.Bot.URL, &http.Client{Timeout: cfg.Bot.Timeout}), nil
+	case "telegram":
+		if cfg.Telegram.Token == "" {
+			return nil, errors.New("TELEGRAM_TOKEN is required for telegram delivery")
+		}
+		return telegram.NewClient(cfg.Telegram.BaseURL, cfg.Telegram.Token, &http.Client{Timeout: 10 * time.Second}), nil
+	case "queue":
+		if cfg.Queue.Broker != "memory" {
+			return nil, fmt.Errorf("unsupported queue broker %q", cfg.Queue.Broker)
+		}
+		// внешнего брокера пока нет: встроенный теряет сообщения при перезапуске
+		// и недоступен другим процессам, так что уведомления из него никто не доставит
+		if !cfg.Queue.AllowMemory {
+			return nil, errors.New("queue delivery is for tests only: the memory broker has no external consumers, set QUEUE_ALLOW_MEMORY=true to use it anyway")
+		}
+		broker := queue.NewMemoryBroker(cfg.Queue.MemoryCapacity)
+		return queue.NewProducer(broker, cfg.Queue.Topic, cfg.Queue.DeadLetterTopic), nil
+	default:
+		return nil, fmt.Errorf("unknown delivery mode %q", cfg.Delivery)
+	}
+}
 
-	e.Start(cfg.ServerAddr)
+// newRateLimiter создаёт общий для всех источников ограничитель запросов
+func newRateLimiter(cfg config.RateLimitConfig, next http.RoundTripper) *ratelimit.Transport {
+	hosts := make(map[string]ratelimit.Limit, len(cfg.Hosts))
+	for host, l := range cfg.Hosts {
+		hosts[host] = ratelimit.Limit{RPS: l.RPS, Burst: l.Burst}
+	}
+	defaults := ratelimit.Limit{RPS: cfg.Default.RPS, Burst: cfg.Default.Burst}
+	return ratelimit.NewTransport(next, defaults, hosts)
 }
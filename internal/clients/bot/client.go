@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+// Client отправляет обновления в сервис бота.
+// Неудачная отправка не повторяется: повторы выполняет outbox
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient создаёт клиент бота
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    httpClient,
+	}
+}
+
+// apiError - тело ответа бота с ошибкой
+type apiError struct {
+	Description string `json:"description"`
+}
+
+// sendError - ошибка отправки; temporary означает, что запрос стоит повторить
+type sendError struct {
+	status    int
+	message   string
+	temporary bool
+	// пауза из заголовка Retry-After
+	retryAfter time.Duration
+}
+
+func (e *sendError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("bot: unexpected status %d", e.status)
+	}
+	return fmt.Sprintf("bot: unexpected status %d: %s", e.status, e.message)
+}
+
+// RetryAfter возвращает паузу, о которой попросил бот
+func (e *sendError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Is сообщает, что ошибки 4xx, кроме 429, постоянные: повтор получит тот же ответ
+func (e *sendError) Is(target error) bool {
+	return target == clients.ErrPermanent && !e.temporary && e.status >= 400 && e.status < 500
+}
+
+// SendUpdate отправляет обновление боту (POST /updates)
+func (c *Client) SendUpdate(ctx context.Context, update model.LinkUpdate) error {
+	body, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, body)
+}
+
+func (c *Client) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/updates", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var apiErr apiError
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = json.Unmarshal(data, &apiErr)
+	seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+	return &sendError{
+		status:     resp.StatusCode,
+		message:    apiErr.Description,
+		temporary:  resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+		retryAfter: time.Duration(max(seconds, 0)) * time.Second,
+	}
+}
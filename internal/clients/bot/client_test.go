@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = model.LinkUpdate{
+	ID:          1,
+	URL:         "https://github.com/foo/bar",
+	Description: "issue: Bug",
+	TgChatIDs:   []int{10, 20},
+}
+
+func TestSendUpdate(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		wantErr        string
+		permanent      bool
+		wantRetryAfter time.Duration
+	}{
+		{name: "success", status: 200},
+		{name: "server error", status: 503, wantErr: "unexpected status 503"},
+		{name: "rate limit", status: 429, wantErr: "unexpected status 429", wantRetryAfter: 7 * time.Second},
+		{name: "bad request is permanent", status: 400, wantErr: "chat not found", permanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/updates", r.URL.Path)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+				var got map[string]any
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+				assert.Equal(t, []any{10.0, 20.0}, got["tgChatIds"])
+				assert.Equal(t, "https://github.com/foo/bar", got["url"])
+
+				calls++
+				if tt.status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "7")
+				}
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusBadRequest {
+					w.Write([]byte(`{"description": "chat not found", "code": "400"}`))
+				}
+			}))
+			defer srv.Close()
+
+			c := NewClient(srv.URL, srv.Client())
+			err := c.SendUpdate(context.Background(), update)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.permanent, errors.Is(err, clients.ErrPermanent))
+			assert.Equal(t, tt.wantRetryAfter, clients.RetryAfter(err))
+			assert.Equal(t, 1, calls, "client must not retry on its own")
+		})
+	}
+}
+
+func TestSendUpdateTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	httpClient := srv.Client()
+	httpClient.Timeout = 10 * time.Millisecond
+	c := NewClient(srv.URL, httpClient)
+
+	assert.Error(t, c.SendUpdate(context.Background(), update))
+}
+
+func TestSendUpdateCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := NewClient(srv.URL, srv.Client())
+
+	assert.ErrorIs(t, c.SendUpdate(ctx, update), context.Canceled)
+}
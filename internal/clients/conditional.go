@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"net/http"
+
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+// Do выполняет GET-запрос условно: подставляет If-None-Match и If-Modified-Since
+// из прошлого ответа на тот же URL и запоминает новые валидаторы в link.
+// Неизменившийся ресурс возвращается с кодом 304 без тела
+func Do(client *http.Client, req *http.Request, link *model.Link) (*http.Response, error) {
+	key := validatorKey(req)
+	if v, ok := link.Validators[key]; ok {
+		if v.ETag != "" {
+			req.Header.Set("If-None-Match", v.ETag)
+		}
+		if v.LastModified != "" {
+			req.Header.Set("If-Modified-Since", v.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		v := model.Validator{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		if v.ETag != "" || v.LastModified != "" {
+			if link.Validators == nil {
+				link.Validators = make(map[string]model.Validator)
+			}
+			link.Validators[key] = v
+		} else {
+			delete(link.Validators, key)
+		}
+	}
+	return resp, nil
+}
+
+// validatorKey - URL запроса без query: параметры вроде since меняются
+// с каждой проверкой, а совпадение валидаторов всё равно проверяет сервер
+func validatorKey(req *http.Request) string {
+	u := *req.URL
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,20 @@
+package clients
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPermanent - получатель отклонил уведомление так, что повтор не поможет:
+// чат не найден, бот заблокирован, запрос неверен. Такие ошибки outbox не повторяет
+var ErrPermanent = errors.New("permanent delivery error")
+
+// RetryAfter возвращает паузу, которую получатель попросил выдержать перед повтором
+// (ответ 429 с Retry-After или retry_after), и 0, если он её не задал
+func RetryAfter(err error) time.Duration {
+	var e interface{ RetryAfter() time.Duration }
+	if errors.As(err, &e) {
+		return e.RetryAfter()
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+const (
+	// Kind - тип источника, который сохраняется у ссылки на ленту
+	Kind = "feed"
+
+	TypeEntry = "entry"
+
+	// ограничение на размер ленты, чтобы не читать в память что угодно
+	maxFeedSize = 10 << 20
+)
+
+var feedContentTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/xml":       true,
+	"text/xml":              true,
+}
+
+// IsFeedContentType сообщает, является ли Content-Type типом ленты
+func IsFeedContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return feedContentTypes[mediaType]
+}
+
+// Client отслеживает новые записи в RSS 2.0, Atom и JSON Feed лентах
+type Client struct {
+	http *http.Client
+}
+
+// NewClient создаёт клиент лент
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{http: httpClient}
+}
+
+func (c *Client) Kind() string {
+	return Kind
+}
+
+// Probe сообщает, отдаёт ли ссылка ленту
+func (c *Client) Probe(ctx context.Context, link string) bool {
+	contentType, ok := clients.ProbeContentType(ctx, c.http, link)
+	return ok && IsFeedContentType(contentType)
+}
+
+// state - guid'ы записей, которые уже были в ленте
+type state struct {
+	Seen []string `json:"seen"`
+}
+
+// Check возвращает записи, которых не было при предыдущей проверке.
+// При первой проверке событий нет - запоминаются только guid'ы
+func (c *Client) Check(ctx context.Context, link *model.Link) ([]model.Update, error) {
+	entries, modified, err := c.fetch(ctx, link)
+	if err != nil || !modified {
+		// лента не изменилась - состояние оставляем как есть
+		return nil, err
+	}
+
+	var prev state
+	firstCheck := len(link.State) == 0
+	if !firstCheck {
+		if err := json.Unmarshal(link.State, &prev); err != nil {
+			return nil, fmt.Errorf("feed: bad link state: %w", err)
+		}
+	}
+	seen := make(map[string]bool, len(prev.Seen))
+	for _, guid := range prev.Seen {
+		seen[guid] = true
+	}
+
+	// запоминаем только то, что сейчас есть в ленте, чтобы состояние не росло
+	var updates []model.Update
+	current := make([]string, 0, len(entries))
+	for _, e := range entries {
+		current = append(current, e.GUID)
+		if firstCheck || seen[e.GUID] {
+			continue
+		}
+		createdAt := e.Published
+		if createdAt.IsZero() {
+			createdAt = time.Now().UTC()
+		}
+		updates = append(updates, model.Update{
+			Type:      TypeEntry,
+			Title:     e.Title,
+			Author:    e.Author,
+			URL:       e.Link,
+			Preview:   clients.Preview(clients.StripHTML(e.Content)),
+			CreatedAt: createdAt,
+		})
+	}
+
+	newState, err := json.Marshal(state{Seen: current})
+	if err != nil {
+		return nil, err
+	}
+	link.State = newState
+	return updates, nil
+}
+
+// fetch загружает ленту. modified = false, если она не изменилась с прошлой проверки (304)
+func (c *Client) fetch(ctx context.Context, link *model.Link) (entries []entry, modified bool, err error) {
+	u, err := clients.ParseURL(link.Link)
+	if err != nil {
+		return nil, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+
+	resp, err := clients.Do(c.http, req, link)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("feed: unexpected status %d for %s", resp.StatusCode, link.Link)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, false, err
+	}
+	entries, err = parse(data)
+	return entries, true, err
+}
@@ -0,0 +1,202 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rssDoc = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+  <title>Blog</title>
+  <item>
+    <title>Second post</title>
+    <link>https://example.com/2</link>
+    <guid>post-2</guid>
+    <dc:creator>alice</dc:creator>
+    <description>&lt;p&gt;Hello &amp;amp; welcome&lt;/p&gt;</description>
+    <pubDate>Thu, 01 May 2025 13:00:00 +0000</pubDate>
+  </item>
+  <item>
+    <title>First post</title>
+    <link>https://example.com/1</link>
+    <pubDate>Wed, 30 Apr 2025 10:00:00 GMT</pubDate>
+  </item>
+</channel>
+</rss>`
+
+const atomDoc = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Changelog</title>
+  <entry>
+    <id>tag:example.com,2025:v2</id>
+    <title>v2.0.0</title>
+    <link rel="alternate" href="https://example.com/releases/v2"/>
+    <author><name>bob</name></author>
+    <updated>2025-05-01T13:00:00Z</updated>
+    <summary>Breaking changes</summary>
+  </entry>
+</feed>`
+
+const jsonDoc = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Notes",
+  "items": [
+    {"id": 17, "url": "https://example.com/notes/17", "title": "Note",
+     "content_text": "plain text", "date_published": "2025-05-01T13:00:00+03:00",
+     "authors": [{"name": "carol"}]}
+  ]
+}`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    []entry
+		wantErr bool
+	}{
+		{
+			name: "rss",
+			doc:  rssDoc,
+			want: []entry{
+				{
+					GUID: "post-2", Title: "Second post", Link: "https://example.com/2", Author: "alice",
+					Content: "<p>Hello &amp; welcome</p>", Published: time.Date(2025, 5, 1, 13, 0, 0, 0, time.UTC),
+				},
+				{
+					GUID: "https://example.com/1", Title: "First post", Link: "https://example.com/1",
+					Published: time.Date(2025, 4, 30, 10, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "atom",
+			doc:  atomDoc,
+			want: []entry{{
+				GUID: "tag:example.com,2025:v2", Title: "v2.0.0", Link: "https://example.com/releases/v2", Author: "bob",
+				Content: "Breaking changes", Published: time.Date(2025, 5, 1, 13, 0, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name: "json feed",
+			doc:  jsonDoc,
+			want: []entry{{
+				GUID: "17", Title: "Note", Link: "https://example.com/notes/17", Author: "carol",
+				Content: "plain text", Published: time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC),
+			}},
+		},
+		{name: "html page", doc: "<html><body>hi</body></html>", wantErr: true},
+		{name: "plain json", doc: `{"items": []}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse([]byte(tt.doc))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnknownFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsFeedContentType(t *testing.T) {
+	assert.True(t, IsFeedContentType("application/rss+xml; charset=utf-8"))
+	assert.True(t, IsFeedContentType("application/atom+xml"))
+	assert.True(t, IsFeedContentType("application/feed+json"))
+	assert.False(t, IsFeedContentType("text/html; charset=utf-8"))
+	assert.False(t, IsFeedContentType(""))
+}
+
+func newFakeSite(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.Write([]byte(rssDoc))
+	})
+	mux.HandleFunc("/cached", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.Write([]byte(rssDoc))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbe(t *testing.T) {
+	srv := newFakeSite(t)
+	c := NewClient(srv.Client())
+	ctx := context.Background()
+
+	assert.True(t, c.Probe(ctx, srv.URL+"/rss"))
+	assert.False(t, c.Probe(ctx, srv.URL+"/page"))
+	assert.False(t, c.Probe(ctx, srv.URL+"/missing"))
+}
+
+func TestCheck(t *testing.T) {
+	srv := newFakeSite(t)
+	c := NewClient(srv.Client())
+
+	t.Run("first check remembers entries", func(t *testing.T) {
+		link := &model.Link{ID: 1, Link: srv.URL + "/rss", Kind: Kind}
+		updates, err := c.Check(context.Background(), link)
+
+		require.NoError(t, err)
+		assert.Empty(t, updates)
+		assert.JSONEq(t, `{"seen": ["post-2", "https://example.com/1"]}`, string(link.State))
+	})
+
+	t.Run("only unseen entries are reported", func(t *testing.T) {
+		link := &model.Link{ID: 1, Link: srv.URL + "/rss", Kind: Kind, State: []byte(`{"seen": ["https://example.com/1", "gone"]}`)}
+		updates, err := c.Check(context.Background(), link)
+
+		require.NoError(t, err)
+		assert.Equal(t, []model.Update{{
+			Type:      TypeEntry,
+			Title:     "Second post",
+			Author:    "alice",
+			URL:       "https://example.com/2",
+			Preview:   "Hello & welcome",
+			CreatedAt: time.Date(2025, 5, 1, 13, 0, 0, 0, time.UTC),
+		}}, updates)
+		assert.JSONEq(t, `{"seen": ["post-2", "https://example.com/1"]}`, string(link.State))
+	})
+
+	t.Run("not modified keeps state", func(t *testing.T) {
+		link := &model.Link{ID: 1, Link: srv.URL + "/cached", Kind: Kind}
+		_, err := c.Check(context.Background(), link)
+		require.NoError(t, err)
+		require.Equal(t, model.Validator{ETag: `"v1"`}, link.Validators[srv.URL+"/cached"])
+		state := string(link.State)
+
+		updates, err := c.Check(context.Background(), link)
+
+		require.NoError(t, err)
+		assert.Empty(t, updates)
+		assert.Equal(t, state, string(link.State))
+		assert.Equal(t, model.Validator{ETag: `"v1"`}, link.Validators[srv.URL+"/cached"])
+	})
+
+	t.Run("not a feed", func(t *testing.T) {
+		_, err := c.Check(context.Background(), &model.Link{ID: 1, Link: srv.URL + "/page", Kind: Kind})
+		assert.ErrorIs(t, err, ErrUnknownFormat)
+	})
+}
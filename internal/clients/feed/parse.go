@@ -0,0 +1,213 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUnknownFormat - документ не похож ни на RSS, ни на Atom, ни на JSON Feed
+var ErrUnknownFormat = errors.New("feed: unknown format")
+
+// entry - элемент ленты, приведённый к общему виду
+type entry struct {
+	GUID      string
+	Title     string
+	Link      string
+	Author    string
+	Content   string
+	Published time.Time
+}
+
+// parse определяет формат ленты по содержимому и разбирает её
+func parse(data []byte) ([]entry, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		return parseJSON(data)
+	}
+
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+	}
+	switch root.XMLName.Local {
+	case "rss":
+		return parseRSS(data)
+	case "feed":
+		return parseAtom(data)
+	}
+	return nil, ErrUnknownFormat
+}
+
+type rssFeed struct {
+	Items []struct {
+		GUID        string `xml:"guid"`
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Author      string `xml:"author"`
+		Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Description string `xml:"description"`
+		PubDate     string `xml:"pubDate"`
+	} `xml:"channel>item"`
+}
+
+func parseRSS(data []byte) ([]entry, error) {
+	var f rssFeed
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0, len(f.Items))
+	for _, it := range f.Items {
+		author := it.Author
+		if author == "" {
+			author = it.Creator
+		}
+		entries = append(entries, entry{
+			GUID:      firstNonEmpty(it.GUID, it.Link, it.Title),
+			Title:     strings.TrimSpace(it.Title),
+			Link:      strings.TrimSpace(it.Link),
+			Author:    strings.TrimSpace(author),
+			Content:   it.Description,
+			Published: parseDate(it.PubDate),
+		})
+	}
+	return entries, nil
+}
+
+type atomFeed struct {
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Authors []struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+func parseAtom(data []byte) ([]entry, error) {
+	var f atomFeed
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0, len(f.Entries))
+	for _, e := range f.Entries {
+		var link string
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+		var author string
+		if len(e.Authors) > 0 {
+			author = e.Authors[0].Name
+		}
+		entries = append(entries, entry{
+			GUID:      firstNonEmpty(e.ID, link, e.Title),
+			Title:     strings.TrimSpace(e.Title),
+			Link:      link,
+			Author:    strings.TrimSpace(author),
+			Content:   firstNonEmpty(e.Summary, e.Content),
+			Published: parseDate(firstNonEmpty(e.Published, e.Updated)),
+		})
+	}
+	return entries, nil
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeed struct {
+	Version string `json:"version"`
+	Items   []struct {
+		ID            json.RawMessage `json:"id"`
+		URL           string          `json:"url"`
+		Title         string          `json:"title"`
+		ContentText   string          `json:"content_text"`
+		ContentHTML   string          `json:"content_html"`
+		Summary       string          `json:"summary"`
+		DatePublished string          `json:"date_published"`
+		Author        *jsonAuthor     `json:"author"`
+		Authors       []jsonAuthor    `json:"authors"`
+	} `json:"items"`
+}
+
+func parseJSON(data []byte) ([]entry, error) {
+	var f jsonFeed
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(f.Version, "https://jsonfeed.org/version/") {
+		return nil, ErrUnknownFormat
+	}
+
+	entries := make([]entry, 0, len(f.Items))
+	for _, it := range f.Items {
+		var author string
+		if len(it.Authors) > 0 {
+			author = it.Authors[0].Name
+		} else if it.Author != nil {
+			author = it.Author.Name
+		}
+		// по спецификации id - строка, но встречаются и числа
+		id := strings.Trim(string(it.ID), `"`)
+		entries = append(entries, entry{
+			GUID:      firstNonEmpty(id, it.URL, it.Title),
+			Title:     it.Title,
+			Link:      it.URL,
+			Author:    author,
+			Content:   firstNonEmpty(it.Summary, it.ContentText, it.ContentHTML),
+			Published: parseDate(it.DatePublished),
+		})
+	}
+	return entries, nil
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// parseDate разбирает дату в одном из распространённых форматов.
+// Нераспознанная дата считается нулевой
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
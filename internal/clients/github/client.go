@@ -0,0 +1,314 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+const (
+	Kind = "github"
+
+	TypeIssue       = "issue"
+	TypePullRequest = "pull_request"
+	TypeComment     = "comment"
+	TypeRelease     = "release"
+)
+
+// ErrTokenRejected - GitHub не принял токен ссылки: он отозван или истёк.
+// Анонимно приватный репозиторий не виден, поэтому проверка падает, а не обходится без токена
+var ErrTokenRejected = errors.New("github: token rejected")
+
+// reservedOwners - разделы сайта, которые выглядят как владелец репозитория
+var reservedOwners = map[string]bool{
+	"settings": true, "orgs": true, "organizations": true, "users": true, "marketplace": true,
+	"explore": true, "topics": true, "collections": true, "trending": true, "notifications": true,
+	"issues": true, "pulls": true, "sponsors": true, "features": true, "enterprise": true,
+	"login": true, "logout": true, "join": true, "new": true, "search": true, "apps": true,
+	"about": true, "pricing": true, "security": true, "site": true, "codespaces": true,
+	"discussions": true, "account": true, "dashboard": true, "stars": true, "watching": true,
+	"events": true, "contact": true, "customer-stories": true, "readme": true, "team": true,
+}
+
+// Client проверяет репозитории, issues и pull requests через GitHub REST API
+type Client struct {
+	baseURL string
+	http    *http.Client
+	tokens  clients.TokenStore
+}
+
+// NewClient создаёт клиент GitHub API.
+// tokens может быть nil - тогда все запросы анонимные
+func NewClient(baseURL string, httpClient *http.Client, tokens clients.TokenStore) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    httpClient,
+		tokens:  tokens,
+	}
+}
+
+// target - то, на что указывает ссылка
+type target struct {
+	owner  string
+	repo   string
+	number int  // 0 - ссылка на весь репозиторий
+	isPull bool // ссылка на pull request
+}
+
+func (c *Client) Kind() string {
+	return Kind
+}
+
+// Match принимает ссылки на репозитории, issues и pull requests
+func (c *Client) Match(u *url.URL) bool {
+	if clients.Host(u) != "github.com" {
+		return false
+	}
+	_, err := parseLink(u.String())
+	return err == nil
+}
+
+// Canonical оставляет от ссылки владельца, репозиторий и номер issue или pull request.
+// Имена в GitHub не зависят от регистра
+func (c *Client) Canonical(u *url.URL) string {
+	t, err := parseLink(u.String())
+	if err != nil {
+		return u.String()
+	}
+	link := "https://github.com/" + strings.ToLower(t.owner+"/"+t.repo)
+	switch {
+	case t.isPull:
+		link += "/pull/" + strconv.Itoa(t.number)
+	case t.number != 0:
+		link += "/issues/" + strconv.Itoa(t.number)
+	}
+	return link
+}
+
+func parseLink(link string) (*target, error) {
+	u, err := clients.ParseURL(link)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" || reservedOwners[strings.ToLower(parts[0])] {
+		return nil, fmt.Errorf("github: not a repository link: %s", link)
+	}
+
+	t := &target{owner: parts[0], repo: strings.TrimSuffix(parts[1], ".git")}
+	if len(parts) == 2 {
+		return t, nil
+	}
+	if len(parts) >= 4 && (parts[2] == "issues" || parts[2] == "pull") {
+		number, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("github: bad issue number in %s", link)
+		}
+		t.number = number
+		t.isPull = parts[2] == "pull"
+		return t, nil
+	}
+	return nil, fmt.Errorf("github: unsupported link: %s", link)
+}
+
+// Check возвращает события, появившиеся после предыдущей проверки.
+// При первой проверке событий нет - запоминается только время
+func (c *Client) Check(ctx context.Context, link *model.Link) ([]model.Update, error) {
+	t, err := parseLink(link.Link)
+	if err != nil {
+		return nil, err
+	}
+	if link.LastCheckedAt == nil {
+		return nil, nil
+	}
+	since := *link.LastCheckedAt
+
+	token, err := clients.LinkToken(ctx, c.tokens, link)
+	if err != nil {
+		return nil, err
+	}
+	if t.number != 0 {
+		return c.checkIssue(ctx, link, t, since, token)
+	}
+	return c.checkRepo(ctx, link, t, since, token)
+}
+
+type user struct {
+	Login string `json:"login"`
+}
+
+type issue struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	User        user      `json:"user"`
+	CreatedAt   time.Time `json:"created_at"`
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+type comment struct {
+	Body      string    `json:"body"`
+	HTMLURL   string    `json:"html_url"`
+	User      user      `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type release struct {
+	Name        string    `json:"name"`
+	TagName     string    `json:"tag_name"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	Author      user      `json:"author"`
+	Draft       bool      `json:"draft"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+func (c *Client) checkRepo(ctx context.Context, link *model.Link, t *target, since time.Time, token string) ([]model.Update, error) {
+	repoPath := fmt.Sprintf("/repos/%s/%s", t.owner, t.repo)
+	query := url.Values{
+		"state":     {"all"},
+		"since":     {since.UTC().Format(time.RFC3339)},
+		"per_page":  {"100"},
+		"sort":      {"created"},
+		"direction": {"desc"},
+	}
+
+	var issues []issue
+	if err := c.get(ctx, link, repoPath+"/issues", query, token, &issues); err != nil {
+		return nil, err
+	}
+
+	var comments []comment
+	commentsQuery := url.Values{"since": {query.Get("since")}, "per_page": {"100"}}
+	if err := c.get(ctx, link, repoPath+"/issues/comments", commentsQuery, token, &comments); err != nil {
+		return nil, err
+	}
+
+	var releases []release
+	if err := c.get(ctx, link, repoPath+"/releases", url.Values{"per_page": {"30"}}, token, &releases); err != nil {
+		return nil, err
+	}
+
+	var updates []model.Update
+	for _, is := range issues {
+		if !is.CreatedAt.After(since) {
+			continue
+		}
+		updateType := TypeIssue
+		if is.PullRequest != nil {
+			updateType = TypePullRequest
+		}
+		updates = append(updates, model.Update{
+			Type:      updateType,
+			Title:     fmt.Sprintf("#%d %s", is.Number, is.Title),
+			Author:    is.User.Login,
+			URL:       is.HTMLURL,
+			Preview:   clients.Preview(is.Body),
+			CreatedAt: is.CreatedAt,
+		})
+	}
+	updates = append(updates, commentUpdates(comments, since)...)
+	for _, r := range releases {
+		if r.Draft || !r.PublishedAt.After(since) {
+			continue
+		}
+		title := r.Name
+		if title == "" {
+			title = r.TagName
+		}
+		updates = append(updates, model.Update{
+			Type:      TypeRelease,
+			Title:     title,
+			Author:    r.Author.Login,
+			URL:       r.HTMLURL,
+			Preview:   clients.Preview(r.Body),
+			CreatedAt: r.PublishedAt,
+		})
+	}
+	return updates, nil
+}
+
+func (c *Client) checkIssue(ctx context.Context, link *model.Link, t *target, since time.Time, token string) ([]model.Update, error) {
+	query := url.Values{"since": {since.UTC().Format(time.RFC3339)}, "per_page": {"100"}}
+
+	var comments []comment
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", t.owner, t.repo, t.number)
+	if err := c.get(ctx, link, path, query, token, &comments); err != nil {
+		return nil, err
+	}
+
+	// комментарии к коду в pull request'ах лежат отдельно
+	if t.isPull {
+		var reviewComments []comment
+		path = fmt.Sprintf("/repos/%s/%s/pulls/%d/comments", t.owner, t.repo, t.number)
+		if err := c.get(ctx, link, path, query, token, &reviewComments); err != nil {
+			return nil, err
+		}
+		comments = append(comments, reviewComments...)
+	}
+
+	return commentUpdates(comments, since), nil
+}
+
+func commentUpdates(comments []comment, since time.Time) []model.Update {
+	var updates []model.Update
+	for _, cm := range comments {
+		if !cm.CreatedAt.After(since) {
+			continue
+		}
+		updates = append(updates, model.Update{
+			Type:      TypeComment,
+			Author:    cm.User.Login,
+			URL:       cm.HTMLURL,
+			Preview:   clients.Preview(cm.Body),
+			CreatedAt: cm.CreatedAt,
+		})
+	}
+	return updates
+}
+
+// get выполняет GET-запрос к API и декодирует JSON-ответ в out.
+// Если токен отвергнут, запрос повторяется анонимно.
+// Если ресурс не изменился с прошлой проверки (304), out остаётся пустым
+func (c *Client) get(ctx context.Context, link *model.Link, path string, query url.Values, token string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := clients.Do(c.http, req, link)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.StatusCode == http.StatusUnauthorized && token != "" {
+		return fmt.Errorf("%w for %s", ErrTokenRejected, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github: unexpected status %d for %s", resp.StatusCode, path)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
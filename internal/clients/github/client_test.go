@@ -0,0 +1,247 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokens map[int]string
+
+func (f fakeTokens) GetToken(_ context.Context, id int) (string, error) {
+	token, ok := f[id]
+	if !ok {
+		return "", errors.New("no such token")
+	}
+	return token, nil
+}
+
+var since = time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newFakeGitHub поднимает заглушку GitHub API с фиксированными ответами
+func newFakeGitHub(t *testing.T, validToken string) (*httptest.Server, *[]string) {
+	var auth []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/foo/bar/issues", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2025-05-01T12:00:00Z", r.URL.Query().Get("since"))
+		w.Write([]byte(`[
+			{"number": 2, "title": "New PR", "body": "please merge", "html_url": "https://github.com/foo/bar/pull/2",
+			 "user": {"login": "alice"}, "created_at": "2025-05-01T13:00:00Z", "pull_request": {}},
+			{"number": 1, "title": "Old issue", "body": "updated recently", "html_url": "https://github.com/foo/bar/issues/1",
+			 "user": {"login": "bob"}, "created_at": "2025-04-01T10:00:00Z"}
+		]`))
+	})
+	mux.HandleFunc("/repos/foo/bar/issues/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"body": "LGTM", "html_url": "https://github.com/foo/bar/issues/1#issuecomment-1",
+			 "user": {"login": "carol"}, "created_at": "2025-05-01T14:00:00Z"}
+		]`))
+	})
+	mux.HandleFunc("/repos/foo/bar/releases", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"name": "", "tag_name": "v1.1.0", "body": "changelog", "html_url": "https://github.com/foo/bar/releases/v1.1.0",
+			 "author": {"login": "alice"}, "published_at": "2025-05-02T09:00:00Z"},
+			{"name": "v1.0.0", "tag_name": "v1.0.0", "html_url": "https://github.com/foo/bar/releases/v1.0.0",
+			 "author": {"login": "alice"}, "published_at": "2025-01-01T09:00:00Z"}
+		]`))
+	})
+	mux.HandleFunc("/repos/foo/bar/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"body": "first", "html_url": "https://github.com/foo/bar/pull/7#issuecomment-5",
+			 "user": {"login": "dave"}, "created_at": "2025-05-01T12:30:00Z"}
+		]`))
+	})
+	mux.HandleFunc("/repos/foo/bar/pulls/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"body": "nit", "html_url": "https://github.com/foo/bar/pull/7#discussion_r1",
+			 "user": {"login": "erin"}, "created_at": "2025-05-01T12:40:00Z"}
+		]`))
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		auth = append(auth, header)
+		if header != "" && header != "Bearer "+validToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &auth
+}
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		link    string
+		want    *target
+		wantErr bool
+	}{
+		{link: "https://github.com/foo/bar", want: &target{owner: "foo", repo: "bar"}},
+		{link: "github.com/foo/bar.git/", want: &target{owner: "foo", repo: "bar"}},
+		{link: "https://github.com/foo/bar/issues/12", want: &target{owner: "foo", repo: "bar", number: 12}},
+		{link: "https://github.com/foo/bar/pull/3/files", want: &target{owner: "foo", repo: "bar", number: 3, isPull: true}},
+		{link: "https://github.com/foo", wantErr: true},
+		{link: "https://github.com/foo/bar/issues/abc", wantErr: true},
+		{link: "https://github.com/foo/bar/wiki", wantErr: true},
+		{link: "https://github.com/settings/tokens", wantErr: true},
+		{link: "https://github.com/orgs/golang/repositories", wantErr: true},
+		{link: "https://github.com/Marketplace/actions", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			got, err := parseLink(tt.link)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckRepository(t *testing.T) {
+	srv, auth := newFakeGitHub(t, "secret")
+	c := NewClient(srv.URL, srv.Client(), fakeTokens{1: "secret"})
+
+	tokenID := 1
+	link := &model.Link{ID: 1, Link: "https://github.com/foo/bar", TokenID: &tokenID, LastCheckedAt: &since}
+	updates, err := c.Check(context.Background(), link)
+	require.NoError(t, err)
+
+	assert.Equal(t, []model.Update{
+		{
+			Type: TypePullRequest, Title: "#2 New PR", Author: "alice", URL: "https://github.com/foo/bar/pull/2",
+			Preview: "please merge", CreatedAt: time.Date(2025, 5, 1, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			Type: TypeComment, Author: "carol", URL: "https://github.com/foo/bar/issues/1#issuecomment-1",
+			Preview: "LGTM", CreatedAt: time.Date(2025, 5, 1, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			Type: TypeRelease, Title: "v1.1.0", Author: "alice", URL: "https://github.com/foo/bar/releases/v1.1.0",
+			Preview: "changelog", CreatedAt: time.Date(2025, 5, 2, 9, 0, 0, 0, time.UTC),
+		},
+	}, updates)
+	assert.Equal(t, []string{"Bearer secret", "Bearer secret", "Bearer secret"}, *auth)
+}
+
+func TestCheckPullRequest(t *testing.T) {
+	srv, _ := newFakeGitHub(t, "secret")
+	c := NewClient(srv.URL, srv.Client(), nil)
+
+	link := &model.Link{ID: 1, Link: "https://github.com/foo/bar/pull/7", LastCheckedAt: &since}
+	updates, err := c.Check(context.Background(), link)
+	require.NoError(t, err)
+
+	require.Len(t, updates, 2)
+	assert.Equal(t, "dave", updates[0].Author)
+	assert.Equal(t, "erin", updates[1].Author)
+}
+
+func TestCheckTokenRejected(t *testing.T) {
+	srv, auth := newFakeGitHub(t, "secret")
+	c := NewClient(srv.URL, srv.Client(), fakeTokens{1: "expired"})
+
+	tokenID := 1
+	link := &model.Link{ID: 1, Link: "https://github.com/foo/bar/issues/7", TokenID: &tokenID, LastCheckedAt: &since}
+	_, err := c.Check(context.Background(), link)
+
+	assert.ErrorIs(t, err, ErrTokenRejected)
+	assert.Equal(t, []string{"Bearer expired"}, *auth, "rejected token must not fall back to anonymous requests")
+}
+
+func TestCheckFirstTime(t *testing.T) {
+	srv, auth := newFakeGitHub(t, "secret")
+	c := NewClient(srv.URL, srv.Client(), nil)
+
+	updates, err := c.Check(context.Background(), &model.Link{ID: 1, Link: "https://github.com/foo/bar"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, updates)
+	assert.Empty(t, *auth)
+}
+
+func TestCheckTokenUnavailable(t *testing.T) {
+	srv, auth := newFakeGitHub(t, "secret")
+	c := NewClient(srv.URL, srv.Client(), fakeTokens{})
+
+	tokenID := 1
+	link := &model.Link{ID: 1, Link: "https://github.com/foo/bar/issues/7", TokenID: &tokenID, LastCheckedAt: &since}
+	_, err := c.Check(context.Background(), link)
+
+	assert.ErrorContains(t, err, "no such token")
+	assert.Empty(t, *auth, "link with a token must not fall back to anonymous requests")
+}
+
+func TestCheckAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, srv.Client(), nil)
+
+	_, err := c.Check(context.Background(), &model.Link{ID: 1, Link: "https://github.com/foo/bar", LastCheckedAt: &since})
+
+	assert.ErrorContains(t, err, "unexpected status 403")
+}
+
+func TestCheckNotModified(t *testing.T) {
+	var conditional []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte(`[{"body": "hi", "html_url": "https://github.com/foo/bar/issues/1#c1",
+			"user": {"login": "bob"}, "created_at": "2025-05-01T12:30:00Z"}]`))
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, srv.Client(), nil)
+	link := &model.Link{ID: 1, Link: "https://github.com/foo/bar/issues/1", LastCheckedAt: &since}
+
+	updates, err := c.Check(context.Background(), link)
+	require.NoError(t, err)
+	assert.Len(t, updates, 1)
+	assert.Equal(t, map[string]model.Validator{
+		srv.URL + "/repos/foo/bar/issues/1/comments": {ETag: `"abc"`},
+	}, link.Validators)
+
+	updates, err = c.Check(context.Background(), link)
+	require.NoError(t, err)
+	assert.Empty(t, updates)
+	assert.Equal(t, []string{"", `"abc"`}, conditional)
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{link: "https://github.com/foo/bar", want: "https://github.com/foo/bar"},
+		{link: "http://www.github.com/Foo/Bar.git?tab=readme", want: "https://github.com/foo/bar"},
+		{link: "https://github.com/foo/bar/issues/12", want: "https://github.com/foo/bar/issues/12"},
+		{link: "https://github.com/Foo/bar/pull/3/files", want: "https://github.com/foo/bar/pull/3"},
+	}
+
+	c := new(Client)
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			u, err := clients.ParseURL(tt.link)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.Canonical(u))
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package clients
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// PreviewLength - максимальная длина превью текста в обновлении
+const PreviewLength = 200
+
+// Preview схлопывает пробелы и обрезает текст до PreviewLength символов
+func Preview(text string) string {
+	return Truncate(strings.Join(strings.Fields(text), " "))
+}
+
+// Truncate обрезает текст до PreviewLength символов, не трогая переносы строк
+func Truncate(text string) string {
+	if utf8.RuneCountInString(text) <= PreviewLength {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:PreviewLength]) + "…"
+}
+
+// StripHTML убирает теги и раскодирует HTML-сущности
+func StripHTML(text string) string {
+	return html.UnescapeString(tagRe.ReplaceAllString(text, " "))
+}
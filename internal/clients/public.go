@@ -0,0 +1,95 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress - ссылка ведёт на адрес внутренней сети или самого сервера
+var ErrPrivateAddress = errors.New("address is not public")
+
+// reservedNets - специальные сети, которые не покрывают методы net.IP
+var reservedNets = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),     // "эта" сеть
+	mustCIDR("100.64.0.0/10"), // CGNAT
+	mustCIDR("192.0.0.0/24"),  // служебные адреса IETF
+	mustCIDR("198.18.0.0/15"), // тестирование производительности
+	mustCIDR("240.0.0.0/4"),   // зарезервировано, включая 255.255.255.255
+	mustCIDR("64:ff9b::/96"),  // NAT64 - за ним может быть любой IPv4
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// IsPublicIP сообщает, что ip не относится к loopback, частным (RFC 1918, fc00::/7),
+// link-local (в том числе 169.254.169.254 облачных метаданных), multicast
+// и другим специальным сетям
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return false
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckPublicHost разрешает имя хоста и возвращает ErrPrivateAddress,
+// если хотя бы один из его адресов не публичный
+func CheckPublicHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublicIP(ip) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !IsPublicIP(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// NewPublicTransport возвращает транспорт, который соединяется только с публичными адресами.
+// Адрес проверяется уже после разрешения имени, непосредственно перед соединением,
+// поэтому под запрет попадают и редиректы, и смена DNS-записи после проверки ссылки.
+// Прокси из окружения не используется: иначе проверялся бы адрес прокси
+func NewPublicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
@@ -0,0 +1,218 @@
+package ratelimit
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit - ограничение запросов к одному хосту
+type Limit struct {
+	// запросов в секунду в среднем
+	RPS float64
+	// сколько запросов можно сделать подряд без ожидания
+	Burst int
+}
+
+// HostState - текущее состояние ограничителя хоста
+type HostState struct {
+	Host         string     `json:"host"`
+	RPS          float64    `json:"rps"`
+	Burst        int        `json:"burst"`
+	Tokens       float64    `json:"tokens"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+}
+
+const (
+	// ограничитель хоста без запросов дольше idleTTL забывается, если его лимит
+	// уже восстановился: новый будет точно таким же. Иначе хосты из ссылок
+	// пользователей копились бы в памяти бесконечно
+	idleTTL = 10 * time.Minute
+	// как часто искать забытые хосты
+	sweepInterval = time.Minute
+)
+
+type hostLimiter struct {
+	limiter      *rate.Limiter
+	blockedUntil time.Time
+	lastUsed     time.Time
+}
+
+// Transport ограничивает частоту запросов к каждому хосту (token bucket)
+// и приостанавливает запросы к хосту, если тот попросил подождать
+// через Retry-After или исчерпанный X-RateLimit-Remaining
+type Transport struct {
+	next     http.RoundTripper
+	defaults Limit
+	limits   map[string]Limit
+	now      func() time.Time
+
+	mu        sync.Mutex
+	hosts     map[string]*hostLimiter
+	lastSweep time.Time
+}
+
+// NewTransport оборачивает next. limits задаёт ограничения для отдельных хостов,
+// для остальных используется defaults
+func NewTransport(next http.RoundTripper, defaults Limit, limits map[string]Limit) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	normalized := make(map[string]Limit, len(limits))
+	for host, l := range limits {
+		normalized[strings.ToLower(host)] = l
+	}
+	return &Transport{
+		next:     next,
+		defaults: defaults,
+		limits:   normalized,
+		now:      time.Now,
+		hosts:    make(map[string]*hostLimiter),
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	h := t.host(host)
+
+	if err := t.waitBlocked(req, h); err != nil {
+		return nil, err
+	}
+	if err := h.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if until, ok := t.backoff(resp); ok {
+		t.mu.Lock()
+		if until.After(h.blockedUntil) {
+			h.blockedUntil = until
+		}
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+func (t *Transport) host(host string) *hostLimiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if now.Sub(t.lastSweep) >= sweepInterval {
+		t.sweep(now)
+	}
+
+	h, ok := t.hosts[host]
+	if !ok {
+		l, ok := t.limits[host]
+		if !ok {
+			l = t.defaults
+		}
+		limit := rate.Limit(l.RPS)
+		if l.RPS <= 0 {
+			limit = rate.Inf
+		}
+		h = &hostLimiter{limiter: rate.NewLimiter(limit, max(l.Burst, 1))}
+		t.hosts[host] = h
+	}
+	h.lastUsed = now
+	return h
+}
+
+// sweep удаляет ограничители хостов, которые давно не использовались,
+// не заблокированы и успели накопить полный запас запросов. Вызывается под t.mu
+func (t *Transport) sweep(now time.Time) {
+	t.lastSweep = now
+	for host, h := range t.hosts {
+		if now.Sub(h.lastUsed) < idleTTL || h.blockedUntil.After(now) {
+			continue
+		}
+		if h.limiter.Limit() == rate.Inf || h.limiter.TokensAt(now) >= float64(h.limiter.Burst()) {
+			delete(t.hosts, host)
+		}
+	}
+}
+
+// waitBlocked ждёт, пока хост снова разрешит запросы
+func (t *Transport) waitBlocked(req *http.Request, h *hostLimiter) error {
+	t.mu.Lock()
+	wait := h.blockedUntil.Sub(t.now())
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff определяет по ответу, до какого момента хост просит не присылать запросы
+func (t *Transport) backoff(resp *http.Response) (time.Time, bool) {
+	now := t.now()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if v := resp.Header.Get("Retry-After"); v != "" {
+			if secs, err := strconv.Atoi(v); err == nil {
+				return now.Add(time.Duration(secs) * time.Second), true
+			}
+			if at, err := http.ParseTime(v); err == nil {
+				return at, true
+			}
+		}
+	}
+
+	// GitHub: reset - unix-время, Reddit: reset - секунды до сброса
+	remaining, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Remaining"), 64)
+	if err != nil || remaining >= 1 {
+		return time.Time{}, false
+	}
+	reset, err := strconv.ParseFloat(resp.Header.Get("X-RateLimit-Reset"), 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if reset > 1e9 {
+		return time.Unix(int64(reset), 0), true
+	}
+	return now.Add(time.Duration(reset * float64(time.Second))), true
+}
+
+// State возвращает состояние ограничителей всех хостов, к которым были запросы
+func (t *Transport) State() []HostState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	states := make([]HostState, 0, len(t.hosts))
+	for host, h := range t.hosts {
+		state := HostState{
+			Host:   host,
+			RPS:    float64(h.limiter.Limit()),
+			Burst:  h.limiter.Burst(),
+			Tokens: h.limiter.TokensAt(now),
+		}
+		if h.limiter.Limit() == rate.Inf {
+			state.RPS = 0
+		}
+		if h.blockedUntil.After(now) {
+			until := h.blockedUntil
+			state.BlockedUntil = &until
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Host < states[j].Host })
+	return states
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBackoff(t *testing.T) {
+	tr := NewTransport(nil, Limit{}, nil)
+	tr.now = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		want    time.Time
+		wantOK  bool
+	}{
+		{name: "ok response", status: 200, headers: map[string]string{"X-RateLimit-Remaining": "10"}},
+		{name: "retry-after seconds", status: 429, headers: map[string]string{"Retry-After": "30"}, want: now.Add(30 * time.Second), wantOK: true},
+		{
+			name:    "retry-after date",
+			status:  503,
+			headers: map[string]string{"Retry-After": "Thu, 01 May 2025 12:05:00 GMT"},
+			want:    now.Add(5 * time.Minute),
+			wantOK:  true,
+		},
+		{name: "retry-after ignored on success", status: 200, headers: map[string]string{"Retry-After": "30"}},
+		{
+			name:    "github reset is unix time",
+			status:  403,
+			headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1746101100"},
+			want:    time.Unix(1746101100, 0),
+			wantOK:  true,
+		},
+		{
+			name:    "reddit reset is seconds",
+			status:  200,
+			headers: map[string]string{"X-Ratelimit-Remaining": "0.0", "X-Ratelimit-Reset": "42"},
+			want:    now.Add(42 * time.Second),
+			wantOK:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+			got, ok := tr.backoff(resp)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	calls := 0
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}
+		if req.URL.Host == "busy.example" {
+			resp.StatusCode = http.StatusTooManyRequests
+			resp.Header.Set("Retry-After", "60")
+		}
+		return resp, nil
+	})
+	tr := NewTransport(next, Limit{RPS: 100, Burst: 5}, map[string]Limit{"API.GitHub.com": {RPS: 2, Burst: 3}})
+	client := &http.Client{Transport: tr}
+
+	for _, u := range []string{"https://api.github.com/a", "https://api.github.com/b", "https://busy.example/"} {
+		resp, err := client.Get(u)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	states := tr.State()
+	require.Len(t, states, 2)
+	assert.Equal(t, "api.github.com", states[0].Host)
+	assert.Equal(t, 2.0, states[0].RPS)
+	assert.Equal(t, 3, states[0].Burst)
+	assert.InDelta(t, 1, states[0].Tokens, 0.1)
+	assert.Nil(t, states[0].BlockedUntil)
+
+	assert.Equal(t, "busy.example", states[1].Host)
+	assert.Equal(t, 100.0, states[1].RPS)
+	require.NotNil(t, states[1].BlockedUntil)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *states[1].BlockedUntil, time.Second)
+
+	// хост попросил подождать - запрос ждёт и отменяется вместе с контекстом
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "https://busy.example/", nil).WithContext(ctx)
+	_, err := tr.RoundTrip(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 3, calls)
+}
+
+func TestForgetIdleHosts(t *testing.T) {
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}
+		if req.URL.Host == "busy.example" {
+			resp.StatusCode = http.StatusTooManyRequests
+			resp.Header.Set("Retry-After", "3600")
+		}
+		return resp, nil
+	})
+	tr := NewTransport(next, Limit{RPS: 100, Burst: 5}, nil)
+	clock := time.Now()
+	tr.now = func() time.Time { return clock }
+	client := &http.Client{Transport: tr}
+
+	for _, u := range []string{"https://a.example/", "https://b.example/", "https://busy.example/"} {
+		resp, err := client.Get(u)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	require.Len(t, tr.State(), 3)
+
+	// a.example использовался недавно, b.example давно простаивает,
+	// а busy.example ещё заблокирован
+	clock = clock.Add(idleTTL)
+	resp, err := client.Get("https://a.example/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	clock = clock.Add(sweepInterval)
+	resp, err = client.Get("https://c.example/")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	var hosts []string
+	for _, s := range tr.State() {
+		hosts = append(hosts, s.Host)
+	}
+	assert.Equal(t, []string{"a.example", "busy.example", "c.example"}, hosts)
+}
@@ -0,0 +1,257 @@
+package reddit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+const (
+	Kind = "reddit"
+
+	TypePost    = "post"
+	TypeComment = "comment"
+
+	siteURL = "https://www.reddit.com"
+)
+
+// Client отслеживает новые посты в сабреддитах и новые комментарии в тредах
+type Client struct {
+	baseURL   string
+	oauthURL  string
+	userAgent string
+	http      *http.Client
+	tokens    clients.TokenStore
+}
+
+// NewClient создаёт клиент Reddit.
+// Без токена используются публичные JSON-эндпоинты baseURL, с токеном - oauthURL
+func NewClient(baseURL, oauthURL, userAgent string, httpClient *http.Client, tokens clients.TokenStore) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		oauthURL:  strings.TrimRight(oauthURL, "/"),
+		userAgent: userAgent,
+		http:      httpClient,
+		tokens:    tokens,
+	}
+}
+
+// target - сабреддит или тред, на который указывает ссылка
+type target struct {
+	subreddit string
+	threadID  string // пусто - ссылка на весь сабреддит
+}
+
+func (c *Client) Kind() string {
+	return Kind
+}
+
+// Match принимает ссылки на сабреддиты и треды
+func (c *Client) Match(u *url.URL) bool {
+	if host := clients.Host(u); host != "reddit.com" && host != "old.reddit.com" {
+		return false
+	}
+	_, err := parseLink(u.String())
+	return err == nil
+}
+
+// Canonical приводит ссылку к www.reddit.com/r/{sub} или www.reddit.com/r/{sub}/comments/{id}.
+// Имена сабреддитов не зависят от регистра, slug обсуждения необязателен
+func (c *Client) Canonical(u *url.URL) string {
+	t, err := parseLink(u.String())
+	if err != nil {
+		return u.String()
+	}
+	link := "https://www.reddit.com/r/" + strings.ToLower(t.subreddit)
+	if t.threadID != "" {
+		link += "/comments/" + strings.ToLower(t.threadID)
+	}
+	return link
+}
+
+// parseLink разбирает ссылки вида reddit.com/r/{sub}
+// и reddit.com/r/{sub}/comments/{id}/{slug}
+func parseLink(link string) (*target, error) {
+	u, err := clients.ParseURL(link)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "r" || parts[1] == "" {
+		return nil, fmt.Errorf("reddit: not a subreddit link: %s", link)
+	}
+
+	t := &target{subreddit: parts[1]}
+	if len(parts) >= 4 && parts[2] == "comments" {
+		t.threadID = parts[3]
+		return t, nil
+	}
+	// reddit.com/r/{sub}/new, /hot и т.п. - тоже сабреддит
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("reddit: unsupported link: %s", link)
+	}
+	return t, nil
+}
+
+type listing struct {
+	Data struct {
+		Children []thing `json:"children"`
+	} `json:"data"`
+}
+
+type thing struct {
+	Kind string    `json:"kind"`
+	Data thingData `json:"data"`
+}
+
+type thingData struct {
+	Title      string  `json:"title"`
+	Author     string  `json:"author"`
+	Permalink  string  `json:"permalink"`
+	Score      int     `json:"score"`
+	CreatedUTC float64 `json:"created_utc"`
+	Selftext   string  `json:"selftext"`
+	Body       string  `json:"body"`
+	// listing с ответами или пустая строка, если ответов нет
+	Replies json.RawMessage `json:"replies"`
+}
+
+func (d thingData) createdAt() time.Time {
+	return time.Unix(int64(d.CreatedUTC), 0).UTC()
+}
+
+// Check возвращает посты или комментарии, появившиеся после предыдущей проверки.
+// При первой проверке событий нет - запоминается только время
+func (c *Client) Check(ctx context.Context, link *model.Link) ([]model.Update, error) {
+	t, err := parseLink(link.Link)
+	if err != nil {
+		return nil, err
+	}
+	if link.LastCheckedAt == nil {
+		return nil, nil
+	}
+	since := *link.LastCheckedAt
+	token, err := clients.LinkToken(ctx, c.tokens, link)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.threadID != "" {
+		return c.checkThread(ctx, link, t, since, token)
+	}
+	return c.checkSubreddit(ctx, link, t, since, token)
+}
+
+func (c *Client) checkSubreddit(ctx context.Context, link *model.Link, t *target, since time.Time, token string) ([]model.Update, error) {
+	var posts listing
+	if _, err := c.get(ctx, link, "/r/"+t.subreddit+"/new", token, &posts); err != nil {
+		return nil, err
+	}
+
+	var updates []model.Update
+	for _, p := range posts.Data.Children {
+		if p.Kind != "t3" || !p.Data.createdAt().After(since) {
+			continue
+		}
+		updates = append(updates, model.Update{
+			Type:      TypePost,
+			Title:     p.Data.Title,
+			Author:    p.Data.Author,
+			URL:       siteURL + p.Data.Permalink,
+			Preview:   clients.Preview(p.Data.Selftext),
+			Score:     p.Data.Score,
+			CreatedAt: p.Data.createdAt(),
+		})
+	}
+	return updates, nil
+}
+
+func (c *Client) checkThread(ctx context.Context, link *model.Link, t *target, since time.Time, token string) ([]model.Update, error) {
+	// ответ - два listing'а: сам пост и дерево комментариев
+	var thread []listing
+	modified, err := c.get(ctx, link, "/comments/"+t.threadID, token, &thread)
+	if err != nil || !modified {
+		return nil, err
+	}
+	if len(thread) != 2 || len(thread[0].Data.Children) == 0 {
+		return nil, fmt.Errorf("reddit: unexpected thread response for %s", t.threadID)
+	}
+	title := thread[0].Data.Children[0].Data.Title
+
+	var updates []model.Update
+	var walk func(things []thing)
+	walk = func(things []thing) {
+		for _, cm := range things {
+			if cm.Kind != "t1" {
+				continue
+			}
+			if cm.Data.createdAt().After(since) {
+				updates = append(updates, model.Update{
+					Type:      TypeComment,
+					Title:     title,
+					Author:    cm.Data.Author,
+					URL:       siteURL + cm.Data.Permalink,
+					Preview:   clients.Preview(cm.Data.Body),
+					Score:     cm.Data.Score,
+					CreatedAt: cm.Data.createdAt(),
+				})
+			}
+			if bytes.HasPrefix(bytes.TrimSpace(cm.Data.Replies), []byte("{")) {
+				var replies listing
+				if err := json.Unmarshal(cm.Data.Replies, &replies); err == nil {
+					walk(replies.Data.Children)
+				}
+			}
+		}
+	}
+	walk(thread[1].Data.Children)
+
+	return updates, nil
+}
+
+// get запрашивает JSON и декодирует его в out.
+// modified = false, если ответ не изменился с прошлой проверки (304)
+func (c *Client) get(ctx context.Context, link *model.Link, path, token string, out any) (modified bool, err error) {
+	query := url.Values{"limit": {"100"}, "raw_json": {"1"}}
+	endpoint := c.baseURL + path + ".json"
+	if token != "" {
+		endpoint = c.oauthURL + path
+	}
+	if strings.HasPrefix(path, "/comments/") {
+		query.Set("sort", "new")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if token != "" {
+		req.Header.Set("Authorization", "bearer "+token)
+	}
+
+	resp, err := clients.Do(c.http, req, link)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("reddit: unexpected status %d for %s", resp.StatusCode, path)
+	}
+	return true, json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,178 @@
+package reddit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokens map[int]string
+
+func (f fakeTokens) GetToken(_ context.Context, id int) (string, error) {
+	token, ok := f[id]
+	if !ok {
+		return "", errors.New("no such token")
+	}
+	return token, nil
+}
+
+// 2025-05-01 12:00:00 UTC
+var since = time.Unix(1746100800, 0).UTC()
+
+// newFakeReddit поднимает заглушку, отвечающую и на публичные, и на OAuth-эндпоинты
+func newFakeReddit(t *testing.T) *httptest.Server {
+	subreddit := []byte(`{"kind": "Listing", "data": {"children": [
+		{"kind": "t3", "data": {"title": "Go 1.25 released", "author": "gopher", "score": 42,
+		 "permalink": "/r/golang/comments/abc/go_125_released/", "created_utc": 1746104400.0, "selftext": "Changelog inside"}},
+		{"kind": "t3", "data": {"title": "Old post", "author": "someone", "score": 1,
+		 "permalink": "/r/golang/comments/old/old_post/", "created_utc": 1746000000.0}}
+	]}}`)
+	thread := []byte(`[
+		{"kind": "Listing", "data": {"children": [
+			{"kind": "t3", "data": {"title": "Go 1.25 released", "author": "gopher", "created_utc": 1746000000.0}}
+		]}},
+		{"kind": "Listing", "data": {"children": [
+			{"kind": "t1", "data": {"author": "old", "body": "seen already", "score": 3,
+			 "permalink": "/r/golang/comments/abc/go_125_released/c1/", "created_utc": 1746090000.0,
+			 "replies": {"kind": "Listing", "data": {"children": [
+				{"kind": "t1", "data": {"author": "alice", "body": "nested reply", "score": 5,
+				 "permalink": "/r/golang/comments/abc/go_125_released/c2/", "created_utc": 1746104400.0, "replies": ""}}
+			 ]}}}},
+			{"kind": "more", "data": {}}
+		]}}
+	]`)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "scraptor-test", r.Header.Get("User-Agent"))
+		switch r.URL.Path {
+		case "/r/golang/new.json":
+			w.Write(subreddit)
+		case "/oauth/r/golang/new":
+			if r.Header.Get("Authorization") != "bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write(subreddit)
+		case "/comments/abc.json":
+			assert.Equal(t, "new", r.URL.Query().Get("sort"))
+			w.Write(thread)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		link    string
+		want    *target
+		wantErr bool
+	}{
+		{link: "https://www.reddit.com/r/golang", want: &target{subreddit: "golang"}},
+		{link: "reddit.com/r/golang/new/", want: &target{subreddit: "golang"}},
+		{link: "https://www.reddit.com/r/golang/comments/abc/go_125_released/", want: &target{subreddit: "golang", threadID: "abc"}},
+		{link: "https://www.reddit.com/user/gopher", wantErr: true},
+		{link: "https://www.reddit.com/r/golang/wiki/faq/x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			got, err := parseLink(tt.link)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckSubreddit(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokenID *int
+	}{
+		{name: "anonymous"},
+		{name: "oauth", tokenID: new(int)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeReddit(t)
+			c := NewClient(srv.URL, srv.URL+"/oauth", "scraptor-test", srv.Client(), fakeTokens{0: "secret"})
+
+			link := &model.Link{ID: 1, Link: "https://www.reddit.com/r/golang", TokenID: tt.tokenID, LastCheckedAt: &since}
+			updates, err := c.Check(context.Background(), link)
+			require.NoError(t, err)
+
+			assert.Equal(t, []model.Update{{
+				Type:      TypePost,
+				Title:     "Go 1.25 released",
+				Author:    "gopher",
+				URL:       "https://www.reddit.com/r/golang/comments/abc/go_125_released/",
+				Preview:   "Changelog inside",
+				Score:     42,
+				CreatedAt: time.Unix(1746104400, 0).UTC(),
+			}}, updates)
+		})
+	}
+}
+
+func TestCheckThread(t *testing.T) {
+	srv := newFakeReddit(t)
+	c := NewClient(srv.URL, srv.URL+"/oauth", "scraptor-test", srv.Client(), nil)
+
+	link := &model.Link{ID: 1, Link: "https://www.reddit.com/r/golang/comments/abc/go_125_released/", LastCheckedAt: &since}
+	updates, err := c.Check(context.Background(), link)
+	require.NoError(t, err)
+
+	assert.Equal(t, []model.Update{{
+		Type:      TypeComment,
+		Title:     "Go 1.25 released",
+		Author:    "alice",
+		URL:       "https://www.reddit.com/r/golang/comments/abc/go_125_released/c2/",
+		Preview:   "nested reply",
+		Score:     5,
+		CreatedAt: time.Unix(1746104400, 0).UTC(),
+	}}, updates)
+}
+
+func TestCheckNotFound(t *testing.T) {
+	srv := newFakeReddit(t)
+	c := NewClient(srv.URL, srv.URL+"/oauth", "scraptor-test", srv.Client(), nil)
+
+	_, err := c.Check(context.Background(), &model.Link{ID: 1, Link: "https://www.reddit.com/r/unknown", LastCheckedAt: &since})
+
+	assert.ErrorContains(t, err, "unexpected status 404")
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{link: "https://www.reddit.com/r/golang", want: "https://www.reddit.com/r/golang"},
+		{link: "https://old.reddit.com/r/GoLang/new", want: "https://www.reddit.com/r/golang"},
+		{link: "reddit.com/r/golang/comments/abc123/some_title", want: "https://www.reddit.com/r/golang/comments/abc123"},
+	}
+
+	c := new(Client)
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			u, err := clients.ParseURL(tt.link)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.Canonical(u))
+		})
+	}
+}
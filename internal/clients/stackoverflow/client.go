@@ -0,0 +1,260 @@
+package stackoverflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+const (
+	Kind = "stackoverflow"
+
+	TypeAnswer  = "answer"
+	TypeComment = "comment"
+
+	site = "stackoverflow"
+)
+
+// Client отслеживает ответы и комментарии к вопросам через StackExchange API
+type Client struct {
+	baseURL string
+	key     string
+	http    *http.Client
+}
+
+// NewClient создаёт клиент StackExchange API, key может быть пустым
+func NewClient(baseURL, key string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		key:     key,
+		http:    httpClient,
+	}
+}
+
+func (c *Client) Kind() string {
+	return Kind
+}
+
+// Match принимает ссылки на вопросы
+func (c *Client) Match(u *url.URL) bool {
+	if clients.Host(u) != "stackoverflow.com" {
+		return false
+	}
+	_, err := parseQuestionID(u.String())
+	return err == nil
+}
+
+// Canonical оставляет от ссылки только id вопроса: slug в пути необязателен
+func (c *Client) Canonical(u *url.URL) string {
+	id, err := parseQuestionID(u.String())
+	if err != nil {
+		return u.String()
+	}
+	return "https://stackoverflow.com/questions/" + strconv.Itoa(id)
+}
+
+// parseQuestionID достаёт id вопроса из ссылок вида
+// stackoverflow.com/questions/{id}/{slug} и stackoverflow.com/q/{id}
+func parseQuestionID(link string) (int, error) {
+	u, err := clients.ParseURL(link)
+	if err != nil {
+		return 0, err
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || (parts[0] != "questions" && parts[0] != "q") {
+		return 0, fmt.Errorf("stackoverflow: not a question link: %s", link)
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("stackoverflow: bad question id in %s", link)
+	}
+	return id, nil
+}
+
+type owner struct {
+	DisplayName string `json:"display_name"`
+}
+
+type item struct {
+	Owner        owner  `json:"owner"`
+	CreationDate int64  `json:"creation_date"`
+	Body         string `json:"body"`
+	AnswerID     int    `json:"answer_id"`
+	CommentID    int    `json:"comment_id"`
+	// пост, к которому оставлен комментарий: вопрос или ответ
+	PostID int `json:"post_id"`
+}
+
+type response struct {
+	Items        []item `json:"items"`
+	ErrorID      int    `json:"error_id"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// maxPostIDs - сколько id принимает один запрос к API. Комментарии запрашиваются
+// к вопросу и последним maxPostIDs-1 ответам
+const maxPostIDs = 100
+
+// state - ответы и комментарии, уже отправленные в уведомлениях.
+// creation_date у API с точностью до секунды, поэтому запрос начинается с секунды
+// прошлой проверки и без этого списка повторил бы созданное в ту же секунду
+type state struct {
+	Seen []string `json:"seen,omitempty"`
+}
+
+// Check возвращает ответы и комментарии к вопросу и его ответам, появившиеся
+// после предыдущей проверки. При первой проверке событий нет - запоминается только время
+func (c *Client) Check(ctx context.Context, link *model.Link) ([]model.Update, error) {
+	questionID, err := parseQuestionID(link.Link)
+	if err != nil {
+		return nil, err
+	}
+	if link.LastCheckedAt == nil {
+		return nil, nil
+	}
+	since := *link.LastCheckedAt
+
+	var prev state
+	if len(link.State) > 0 {
+		if err := json.Unmarshal(link.State, &prev); err != nil {
+			return nil, fmt.Errorf("stackoverflow: bad link state: %w", err)
+		}
+	}
+	seen := make(map[string]bool, len(prev.Seen))
+	for _, key := range prev.Seen {
+		seen[key] = true
+	}
+
+	// все ответы нужны ради их id: комментарии приходят и к старым ответам,
+	// поэтому этот запрос не условный - 304 оставил бы без id
+	answers, _, err := c.fetch(ctx, nil, fmt.Sprintf("/questions/%d/answers", questionID), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	posts := []string{strconv.Itoa(questionID)}
+	for _, a := range answers {
+		if len(posts) == maxPostIDs {
+			break
+		}
+		posts = append(posts, strconv.Itoa(a.AnswerID))
+	}
+	comments, modified, err := c.fetch(ctx, link, "/posts/"+strings.Join(posts, ";")+"/comments", since)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []model.Update
+	var current []string
+	for _, a := range answers {
+		key := "a" + strconv.Itoa(a.AnswerID)
+		if a.CreationDate < since.Unix() {
+			continue
+		}
+		current = append(current, key)
+		if seen[key] {
+			continue
+		}
+		updates = append(updates, model.Update{
+			Type:      TypeAnswer,
+			Author:    html.UnescapeString(a.Owner.DisplayName),
+			URL:       fmt.Sprintf("https://stackoverflow.com/a/%d", a.AnswerID),
+			Preview:   clients.Preview(clients.StripHTML(a.Body)),
+			CreatedAt: time.Unix(a.CreationDate, 0).UTC(),
+		})
+	}
+	if !modified {
+		// комментарии не изменились - отправленные остаются отправленными
+		for _, key := range prev.Seen {
+			if strings.HasPrefix(key, "c") {
+				current = append(current, key)
+			}
+		}
+	}
+	for _, cm := range comments {
+		key := "c" + strconv.Itoa(cm.CommentID)
+		current = append(current, key)
+		if seen[key] {
+			continue
+		}
+		postID := cm.PostID
+		if postID == 0 {
+			postID = questionID
+		}
+		updates = append(updates, model.Update{
+			Type:      TypeComment,
+			Author:    html.UnescapeString(cm.Owner.DisplayName),
+			URL:       fmt.Sprintf("https://stackoverflow.com/questions/%d#comment%d_%d", questionID, cm.CommentID, postID),
+			Preview:   clients.Preview(clients.StripHTML(cm.Body)),
+			CreatedAt: time.Unix(cm.CreationDate, 0).UTC(),
+		})
+	}
+
+	newState, err := json.Marshal(state{Seen: current})
+	if err != nil {
+		return nil, err
+	}
+	link.State = newState
+	return updates, nil
+}
+
+// fetch запрашивает последние элементы, созданные не раньше секунды since.
+// Нулевой since - без ограничения по времени. С link запрос условный,
+// и modified = false означает, что с прошлого раза ничего не изменилось
+func (c *Client) fetch(ctx context.Context, link *model.Link, path string, since time.Time) (items []item, modified bool, err error) {
+	query := url.Values{
+		"site":     {site},
+		"filter":   {"withbody"},
+		"sort":     {"creation"},
+		"order":    {"desc"},
+		"pagesize": {"100"},
+	}
+	if !since.IsZero() {
+		// fromdate включительный и с точностью до секунды:
+		// созданное в ту же секунду, что и since, отсеивается по state
+		query.Set("fromdate", strconv.FormatInt(since.Unix(), 10))
+	}
+	if c.key != "" {
+		query.Set("key", c.key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var resp *http.Response
+	if link != nil {
+		resp, err = clients.Do(c.http, req, link)
+	} else {
+		resp, err = c.http.Do(req)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+
+	var body response
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, false, fmt.Errorf("stackoverflow: bad response for %s (status %d): %w", path, resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.ErrorID != 0 {
+		return nil, false, fmt.Errorf("stackoverflow: status %d for %s: %s", resp.StatusCode, path, body.ErrorMessage)
+	}
+	return body.Items, true, nil
+}
@@ -0,0 +1,160 @@
+package stackoverflow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var since = time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newFakeAPI поднимает заглушку StackExchange API
+func newFakeAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/questions/42/answers", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "stackoverflow", r.URL.Query().Get("site"))
+		assert.Equal(t, "withbody", r.URL.Query().Get("filter"))
+		assert.Empty(t, r.URL.Query().Get("fromdate"), "all answers are needed for their comments")
+		assert.Equal(t, "secret", r.URL.Query().Get("key"))
+		// 100 - новый ответ, 99 - создан в ту же секунду, что и прошлая проверка, 90 - старый
+		w.Write([]byte(`{"items": [
+			{"owner": {"display_name": "J&#246;rg"}, "creation_date": 1746104400, "answer_id": 100,
+			 "body": "<p>Use <code>sync.Once</code> &amp; be happy</p>"},
+			{"owner": {"display_name": "carol"}, "creation_date": 1746100800, "answer_id": 99, "body": "Same second"},
+			{"owner": {"display_name": "dave"}, "creation_date": 1746090000, "answer_id": 90, "body": "Old"}
+		], "has_more": false}`))
+	})
+	mux.HandleFunc("/posts/42;100;99;90/comments", func(w http.ResponseWriter, r *http.Request) {
+		// fromdate включительный: созданное в секунду прошлой проверки не теряется
+		assert.Equal(t, strconv.FormatInt(since.Unix(), 10), r.URL.Query().Get("fromdate"))
+		w.Write([]byte(`{"items": [
+			{"owner": {"display_name": "bob"}, "creation_date": 1746108000, "comment_id": 7, "post_id": 42, "body": "Thanks!"},
+			{"owner": {"display_name": "erin"}, "creation_date": 1746107000, "comment_id": 8, "post_id": 90, "body": "Still works"}
+		]}`))
+	})
+	mux.HandleFunc("/questions/13/answers", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error_id": 502, "error_name": "throttle_violation", "error_message": "too many requests"}`))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestParseQuestionID(t *testing.T) {
+	tests := []struct {
+		link    string
+		want    int
+		wantErr bool
+	}{
+		{link: "https://stackoverflow.com/questions/42/how-to-do-x", want: 42},
+		{link: "stackoverflow.com/q/17", want: 17},
+		{link: "https://stackoverflow.com/users/1", wantErr: true},
+		{link: "https://stackoverflow.com/questions/tagged", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			got, err := parseQuestionID(tt.link)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	srv := newFakeAPI(t)
+	c := NewClient(srv.URL, "secret", srv.Client())
+
+	// ответ 99 уже был в уведомлениях прошлой проверки
+	link := &model.Link{
+		ID:            1,
+		Link:          "https://stackoverflow.com/questions/42/how-to-do-x",
+		LastCheckedAt: &since,
+		State:         []byte(`{"seen": ["a99"]}`),
+	}
+	updates, err := c.Check(context.Background(), link)
+	require.NoError(t, err)
+
+	assert.Equal(t, []model.Update{
+		{
+			Type:      TypeAnswer,
+			Author:    "Jörg",
+			URL:       "https://stackoverflow.com/a/100",
+			Preview:   "Use sync.Once & be happy",
+			CreatedAt: time.Unix(1746104400, 0).UTC(),
+		},
+		{
+			Type:      TypeComment,
+			Author:    "bob",
+			URL:       "https://stackoverflow.com/questions/42#comment7_42",
+			Preview:   "Thanks!",
+			CreatedAt: time.Unix(1746108000, 0).UTC(),
+		},
+		{
+			Type:      TypeComment,
+			Author:    "erin",
+			URL:       "https://stackoverflow.com/questions/42#comment8_90",
+			Preview:   "Still works",
+			CreatedAt: time.Unix(1746107000, 0).UTC(),
+		},
+	}, updates)
+	assert.JSONEq(t, `{"seen": ["a100", "a99", "c7", "c8"]}`, string(link.State))
+
+	// повторная проверка с той же секунды ничего не повторяет
+	updates, err = c.Check(context.Background(), link)
+	require.NoError(t, err)
+	assert.Empty(t, updates)
+}
+
+func TestCheckFirstTime(t *testing.T) {
+	srv := newFakeAPI(t)
+	c := NewClient(srv.URL, "", srv.Client())
+
+	updates, err := c.Check(context.Background(), &model.Link{ID: 1, Link: "https://stackoverflow.com/questions/42"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, updates)
+}
+
+func TestCheckAPIError(t *testing.T) {
+	srv := newFakeAPI(t)
+	c := NewClient(srv.URL, "", srv.Client())
+
+	_, err := c.Check(context.Background(), &model.Link{ID: 1, Link: "https://stackoverflow.com/questions/13", LastCheckedAt: &since})
+
+	assert.ErrorContains(t, err, "too many requests")
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{link: "https://stackoverflow.com/questions/123", want: "https://stackoverflow.com/questions/123"},
+		{link: "https://stackoverflow.com/questions/123/how-to-go", want: "https://stackoverflow.com/questions/123"},
+		{link: "http://www.stackoverflow.com/q/123?noredirect=1", want: "https://stackoverflow.com/questions/123"},
+	}
+
+	c := new(Client)
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			u, err := clients.ParseURL(tt.link)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.Canonical(u))
+		})
+	}
+}
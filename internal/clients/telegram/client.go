@@ -0,0 +1,153 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+// Client отправляет уведомления напрямую через Telegram Bot API.
+// Неудачная отправка не повторяется: повторы выполняет outbox,
+// с паузой не меньше retry_after из ответа 429
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient создаёт клиент Telegram Bot API
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    httpClient,
+	}
+}
+
+type sendMessageRequest struct {
+	ChatID            int    `json:"chat_id"`
+	Text              string `json:"text"`
+	ParseMode         string `json:"parse_mode"`
+	DisableWebPreview bool   `json:"disable_web_page_preview"`
+}
+
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// APIError - ошибка, которую вернул Telegram
+type APIError struct {
+	Code        int
+	Description string
+	// сколько секунд ждать перед повтором после ответа 429
+	RetryAfterSeconds int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram: error %d: %s", e.Code, e.Description)
+}
+
+// RetryAfter возвращает паузу, о которой попросил Telegram
+func (e *APIError) RetryAfter() time.Duration {
+	return time.Duration(e.RetryAfterSeconds) * time.Second
+}
+
+// Is сообщает, что ошибки 4xx, кроме 429, постоянные: повтор получит тот же ответ
+func (e *APIError) Is(target error) bool {
+	return target == clients.ErrPermanent &&
+		e.Code >= 400 && e.Code < 500 && e.Code != http.StatusTooManyRequests
+}
+
+// SendUpdate отправляет обновление в каждый чат из update.TgChatIDs.
+// Ошибка в одном чате не мешает отправке в остальные. Если часть сообщений
+// уже доставлена, ошибка помечается как clients.ErrPermanent: повтор отправил бы
+// их ещё раз, поэтому уведомление уходит в dead letters, а не повторяется.
+// Планировщик адресует каждое уведомление одному чату, так что это касается
+// только длинных уведомлений, разбитых на несколько сообщений
+func (c *Client) SendUpdate(ctx context.Context, update model.LinkUpdate) error {
+	messages := formatMessage(update.URL, update.Description, MaxMessageLength)
+
+	var errs []error
+	sent := 0
+	for _, chatID := range update.TgChatIDs {
+		for i, text := range messages {
+			if err := c.sendMessage(ctx, chatID, text); err != nil {
+				errs = append(errs, fmt.Errorf("chat %d: part %d of %d: %w", chatID, i+1, len(messages), err))
+				break
+			}
+			sent++
+		}
+	}
+	err := errors.Join(errs...)
+	if err != nil && sent > 0 {
+		return fmt.Errorf("%w: %d messages already delivered: %w", clients.ErrPermanent, sent, err)
+	}
+	return err
+}
+
+// sendMessage вызывает sendMessage
+func (c *Client) sendMessage(ctx context.Context, chatID int, text string) error {
+	body, err := json.Marshal(sendMessageRequest{
+		ChatID:            chatID,
+		Text:              text,
+		ParseMode:         "MarkdownV2",
+		DisableWebPreview: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.call(ctx, "sendMessage", body)
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return &APIError{
+			Code:              resp.ErrorCode,
+			Description:       resp.Description,
+			RetryAfterSeconds: resp.Parameters.RetryAfter,
+		}
+	}
+	return nil
+}
+
+func (c *Client) call(ctx context.Context, method string, body []byte) (*apiResponse, error) {
+	endpoint := fmt.Sprintf("%s/bot%s/%s", c.baseURL, c.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// в тексте ошибки есть URL, а в нём - токен бота
+		return nil, errors.New(strings.ReplaceAll(err.Error(), c.token, "<token>"))
+	}
+	defer resp.Body.Close()
+
+	var out apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("telegram: bad response with status %d: %w", resp.StatusCode, err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,199 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeMarkdown(t *testing.T) {
+	assert.Equal(t, `v1\.2 \- fix \*bold\* \[x\]\(y\) a\_b c\\d`, EscapeMarkdown(`v1.2 - fix *bold* [x](y) a_b c\d`))
+}
+
+func TestFormatMessage(t *testing.T) {
+	t.Run("single message", func(t *testing.T) {
+		got := formatMessage("https://example.com/a_(b)", "issue: Bug!\nАвтор: alice", MaxMessageLength)
+		assert.Equal(t, []string{
+			"[https://example\\.com/a\\_\\(b\\)](https://example.com/a_(b\\))\n\nissue: Bug\\!\nАвтор: alice",
+		}, got)
+	})
+
+	t.Run("split by lines", func(t *testing.T) {
+		got := formatMessage("https://x.io", "first line\nsecond line\nthird", 30)
+		assert.Equal(t, []string{
+			"[https://x\\.io](https://x.io)",
+			"first line\nsecond line\nthird",
+		}, got)
+	})
+
+	t.Run("long line is cut without breaking escapes", func(t *testing.T) {
+		line := strings.Repeat("ab.", 10)
+		got := formatMessage("https://x.io", line, 8)
+		require.Greater(t, len(got), 2)
+		for _, msg := range got[1:] {
+			assert.LessOrEqual(t, utf8.RuneCountInString(msg), 8)
+			assert.False(t, strings.HasSuffix(msg, `\`) && !strings.HasSuffix(msg, `\\`), msg)
+		}
+		joined := strings.Join(got[1:], "")
+		assert.Equal(t, EscapeMarkdown(line), joined)
+	})
+}
+
+// fakeTelegram - заглушка Bot API, отвечающая по очереди заданными ответами
+type fakeTelegram struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []sendMessageRequest
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path != "/botsecret/sendMessage" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+		return
+	}
+	var req sendMessageRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	f.requests = append(f.requests, req)
+
+	resp := `{"ok":true,"result":{}}`
+	if len(f.responses) > 0 {
+		resp, f.responses = f.responses[0], f.responses[1:]
+	}
+	w.Write([]byte(resp))
+}
+
+func TestSendUpdate(t *testing.T) {
+	const tooMany = `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 2","parameters":{"retry_after":2}}`
+	const blocked = `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`
+
+	tests := []struct {
+		name           string
+		responses      []string
+		wantChats      []int
+		wantErr        string
+		wantRetryAfter time.Duration
+		// chat 20 уже получил сообщение - повтор продублировал бы его
+		wantPermanent bool
+	}{
+		{name: "every chat gets a message", wantChats: []int{10, 20}},
+		{
+			name:           "429 is left to the caller with retry_after",
+			responses:      []string{tooMany},
+			wantChats:      []int{10, 20},
+			wantErr:        "429",
+			wantRetryAfter: 2 * time.Second,
+			wantPermanent:  true,
+		},
+		{
+			name:          "failed chat does not stop others",
+			responses:     []string{blocked},
+			wantChats:     []int{10, 20},
+			wantErr:       "chat 10",
+			wantPermanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeTelegram{responses: tt.responses}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			c := NewClient(srv.URL, "secret", srv.Client())
+
+			err := c.SendUpdate(context.Background(), model.LinkUpdate{
+				ID:          1,
+				URL:         "https://github.com/foo/bar",
+				Description: "issue: Bug",
+				TgChatIDs:   []int{10, 20},
+			})
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantRetryAfter, clients.RetryAfter(err))
+			assert.Equal(t, tt.wantPermanent, errors.Is(err, clients.ErrPermanent))
+
+			var chats []int
+			for _, req := range fake.requests {
+				chats = append(chats, req.ChatID)
+				assert.Equal(t, "MarkdownV2", req.ParseMode)
+				assert.Equal(t, "[https://github\\.com/foo/bar](https://github.com/foo/bar)\n\nissue: Bug", req.Text)
+			}
+			assert.Equal(t, tt.wantChats, chats)
+		})
+	}
+}
+
+func TestSendUpdatePartial(t *testing.T) {
+	const tooMany = `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":2}}`
+	long := model.LinkUpdate{ID: 1, URL: "https://github.com/foo/bar", Description: strings.Repeat("a ", MaxMessageLength), TgChatIDs: []int{10}}
+
+	t.Run("nothing delivered is retried", func(t *testing.T) {
+		fake := &fakeTelegram{responses: []string{tooMany}}
+		srv := httptest.NewServer(fake)
+		defer srv.Close()
+
+		err := NewClient(srv.URL, "secret", srv.Client()).SendUpdate(context.Background(), long)
+
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, clients.ErrPermanent))
+		assert.Len(t, fake.requests, 1)
+	})
+
+	t.Run("partly delivered is not retried", func(t *testing.T) {
+		fake := &fakeTelegram{responses: []string{`{"ok":true,"result":{}}`, tooMany}}
+		srv := httptest.NewServer(fake)
+		defer srv.Close()
+
+		err := NewClient(srv.URL, "secret", srv.Client()).SendUpdate(context.Background(), long)
+
+		assert.ErrorIs(t, err, clients.ErrPermanent)
+		assert.ErrorContains(t, err, "part 2 of")
+		assert.Len(t, fake.requests, 2)
+	})
+}
+
+func TestAPIErrorIsPermanent(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{code: 400, want: true},
+		{code: 403, want: true},
+		{code: 429, want: false},
+		{code: 500, want: false},
+		{code: 502, want: false},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("chat 1: %w", &APIError{Code: tt.code})
+		assert.Equal(t, tt.want, errors.Is(err, clients.ErrPermanent), "code=%d", tt.code)
+	}
+}
+
+func TestSendUpdateHidesToken(t *testing.T) {
+	c := NewClient("http://127.0.0.1:1", "secret", nil)
+
+	err := c.SendUpdate(context.Background(), model.LinkUpdate{URL: "https://x.io", TgChatIDs: []int{1}})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+}
@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxMessageLength - ограничение Telegram на длину текста сообщения в символах
+const MaxMessageLength = 4096
+
+// спецсимволы MarkdownV2, которые нужно экранировать в тексте
+var markdownReplacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// EscapeMarkdown экранирует текст для parse_mode=MarkdownV2
+func EscapeMarkdown(text string) string {
+	return markdownReplacer.Replace(text)
+}
+
+// внутри (...) ссылки экранируются только ")" и "\"
+var linkReplacer = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+
+// formatMessage собирает текст уведомления и режет его по строкам на сообщения
+// не длиннее limit символов. Ссылка на источник идёт только в первом сообщении.
+// Лимит считается по экранированному тексту - с запасом
+func formatMessage(url, description string, limit int) []string {
+	header := "[" + EscapeMarkdown(url) + "](" + linkReplacer.Replace(url) + ")"
+	if description == "" {
+		return []string{header}
+	}
+
+	var messages []string
+	current := header + "\n\n"
+	for _, part := range strings.SplitAfter(description, "\n") {
+		escaped := EscapeMarkdown(part)
+		if utf8.RuneCountInString(current)+utf8.RuneCountInString(escaped) > limit && current != "" {
+			messages = append(messages, strings.TrimRight(current, "\n"))
+			current = ""
+		}
+		// кусок длиннее лимита даже после переноса - режем по символам
+		for utf8.RuneCountInString(escaped) > limit {
+			cut := cutRunes(escaped, limit)
+			messages = append(messages, cut)
+			escaped = escaped[len(cut):]
+		}
+		current += escaped
+	}
+	if current = strings.TrimRight(current, "\n"); current != "" {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// cutRunes возвращает начало строки длиной не больше n символов,
+// не разрывая экранирующую последовательность
+func cutRunes(s string, n int) string {
+	i, count := 0, 0
+	for i < len(s) && count < n {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == '\\' && count+2 > n {
+			break
+		}
+		if r == '\\' && i+size < len(s) {
+			_, next := utf8.DecodeRuneInString(s[i+size:])
+			i += size + next
+			count += 2
+			continue
+		}
+		i += size
+		count++
+	}
+	return s[:i]
+}
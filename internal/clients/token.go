@@ -0,0 +1,32 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+// TokenStore отдаёт токен доступа по id записи в таблице tokens
+type TokenStore interface {
+	GetToken(ctx context.Context, id int) (string, error)
+}
+
+// LinkToken возвращает токен, закреплённый за ссылкой.
+// Пустая строка означает анонимный доступ - только для ссылок без токена.
+// Если токен задан, но не читается (ошибка БД, сменился ключ), возвращается ошибка:
+// тихий переход на анонимные запросы прятал бы поломку и останавливал приватные ссылки
+func LinkToken(ctx context.Context, store TokenStore, link *model.Link) (string, error) {
+	if link.TokenID == nil {
+		return "", nil
+	}
+	if store == nil {
+		return "", errors.New("token store is not configured")
+	}
+	token, err := store.GetToken(ctx, *link.TokenID)
+	if err != nil {
+		return "", fmt.Errorf("token %d: %w", *link.TokenID, err)
+	}
+	return token, nil
+}
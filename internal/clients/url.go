@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ParseURL разбирает ссылку, подставляя https://, если схема не указана
+func ParseURL(link string) (*url.URL, error) {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	return url.Parse(link)
+}
+
+// Host возвращает хост ссылки в нижнем регистре и без www.
+func Host(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// ProbeContentType запрашивает ссылку и возвращает Content-Type ответа.
+// ok = false, если ссылка недоступна или ответила не 200
+func ProbeContentType(ctx context.Context, client *http.Client, link string) (contentType string, ok bool) {
+	u, err := ParseURL(link)
+	if err != nil {
+		return "", false
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", false
+	}
+	return resp.Header.Get("Content-Type"), true
+}
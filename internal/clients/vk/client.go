@@ -0,0 +1,385 @@
+package vk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+const (
+	Kind = "vk"
+
+	TypeMessage       = "message"
+	TypePost          = "post"
+	TypeFriendRequest = "friend_request"
+
+	siteURL = "https://vk.com"
+)
+
+// ErrNoToken - все методы VK API требуют токен доступа
+var ErrNoToken = errors.New("vk: access token required")
+
+// Mode - что именно отслеживается по ссылке
+type Mode int
+
+const (
+	ModeDialogs Mode = iota + 1
+	ModeWall
+	ModeFriendRequests
+)
+
+// Client отслеживает диалоги, стены сообществ и заявки в друзья через VK API
+type Client struct {
+	baseURL string
+	version string
+	http    *http.Client
+	tokens  clients.TokenStore
+}
+
+// NewClient создаёт клиент VK API
+func NewClient(baseURL, version string, httpClient *http.Client, tokens clients.TokenStore) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		version: version,
+		http:    httpClient,
+		tokens:  tokens,
+	}
+}
+
+// target - режим отслеживания и параметры стены
+type target struct {
+	mode    Mode
+	ownerID int    // владелец стены: <0 - сообщество, >0 - пользователь
+	domain  string // короткое имя стены, если ownerID неизвестен
+}
+
+func (c *Client) Kind() string {
+	return Kind
+}
+
+// RequiresToken - все методы VK API требуют токен
+func (c *Client) RequiresToken() bool {
+	return true
+}
+
+// Match принимает ссылки на диалоги, стены и заявки в друзья
+func (c *Client) Match(u *url.URL) bool {
+	if host := clients.Host(u); host != "vk.com" && host != "m.vk.com" {
+		return false
+	}
+	_, err := parseLink(u.String())
+	return err == nil
+}
+
+// Canonical приводит ссылку к vk.com/{page}: мобильная версия и регистр
+// короткого имени не важны, а параметры нужны только заявкам в друзья
+func (c *Client) Canonical(u *url.URL) string {
+	t, err := parseLink(u.String())
+	if err != nil {
+		return u.String()
+	}
+	if t.mode == ModeFriendRequests {
+		return "https://vk.com/friends?section=requests"
+	}
+	return "https://vk.com/" + strings.ToLower(strings.Trim(u.Path, "/"))
+}
+
+var (
+	numericPageRe = regexp.MustCompile(`^(id|club|public|event)(\d+)$`)
+	// короткие имена VK: латиница, цифры, _ и .
+	screenNameRe = regexp.MustCompile(`^[a-zA-Z0-9_.]{2,32}$`)
+)
+
+// reservedPages - разделы сайта, которые выглядят как короткое имя, но стеной не являются
+var reservedPages = map[string]bool{
+	"feed": true, "settings": true, "groups": true, "friends": true, "im": true,
+	"audio": true, "audios": true, "music": true, "video": true, "videos": true, "clips": true,
+	"photos": true, "albums": true, "docs": true, "apps": true, "games": true,
+	"market": true, "services": true, "search": true, "support": true, "bugs": true,
+	"login": true, "join": true, "restore": true, "about": true, "blog": true,
+	"dev": true, "terms": true, "privacy": true, "bookmarks": true, "fave": true,
+	"notifications": true, "messenger": true, "calls": true, "stories": true,
+	"edit": true, "ads": true, "pay": true, "vkpay": true, "mail": true, "away.php": true,
+}
+
+// parseLink определяет режим по ссылке:
+// vk.com/im - диалоги, vk.com/friends?section=requests - заявки в друзья,
+// vk.com/{club123|public123|id123|screen_name} - стена
+func parseLink(link string) (*target, error) {
+	u, err := clients.ParseURL(link)
+	if err != nil {
+		return nil, err
+	}
+	page := strings.Trim(u.Path, "/")
+	if page == "" || strings.Contains(page, "/") {
+		return nil, fmt.Errorf("vk: unsupported link: %s", link)
+	}
+
+	switch page {
+	case "im":
+		return &target{mode: ModeDialogs}, nil
+	case "friends":
+		if u.Query().Get("section") != "requests" {
+			return nil, fmt.Errorf("vk: only friend requests can be tracked: %s", link)
+		}
+		return &target{mode: ModeFriendRequests}, nil
+	}
+
+	if m := numericPageRe.FindStringSubmatch(page); m != nil {
+		id, _ := strconv.Atoi(m[2])
+		if m[1] != "id" {
+			id = -id
+		}
+		return &target{mode: ModeWall, ownerID: id}, nil
+	}
+	if reservedPages[strings.ToLower(page)] || !screenNameRe.MatchString(page) {
+		return nil, fmt.Errorf("vk: %s is not a wall: %s", page, link)
+	}
+	return &target{mode: ModeWall, domain: page}, nil
+}
+
+// state - то, что запоминается между проверками
+type state struct {
+	// заявки в друзья, которые уже были отправлены в уведомлениях
+	Requests []int `json:"requests,omitempty"`
+}
+
+// Check возвращает события, появившиеся после предыдущей проверки.
+// При первой проверке событий нет - запоминается только текущее состояние
+func (c *Client) Check(ctx context.Context, link *model.Link) ([]model.Update, error) {
+	t, err := parseLink(link.Link)
+	if err != nil {
+		return nil, err
+	}
+	token, err := clients.LinkToken(ctx, c.tokens, link)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	switch t.mode {
+	case ModeFriendRequests:
+		return c.checkFriendRequests(ctx, link, token)
+	case ModeDialogs:
+		if link.LastCheckedAt == nil {
+			return nil, nil
+		}
+		return c.checkDialogs(ctx, *link.LastCheckedAt, token)
+	default:
+		if link.LastCheckedAt == nil {
+			return nil, nil
+		}
+		return c.checkWall(ctx, t, *link.LastCheckedAt, token)
+	}
+}
+
+type message struct {
+	ID     int    `json:"id"`
+	Date   int64  `json:"date"`
+	FromID int    `json:"from_id"`
+	PeerID int    `json:"peer_id"`
+	Out    int    `json:"out"`
+	Text   string `json:"text"`
+}
+
+type conversations struct {
+	Items []struct {
+		Conversation struct {
+			Peer struct {
+				ID int `json:"id"`
+			} `json:"peer"`
+			UnreadCount int `json:"unread_count"`
+		} `json:"conversation"`
+		LastMessage message `json:"last_message"`
+	} `json:"items"`
+}
+
+func (c *Client) checkDialogs(ctx context.Context, since time.Time, token string) ([]model.Update, error) {
+	var resp conversations
+	err := c.call(ctx, "messages.getConversations", url.Values{"count": {"200"}}, token, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []model.Update
+	for _, it := range resp.Items {
+		msg := it.LastMessage
+		createdAt := time.Unix(msg.Date, 0).UTC()
+		if msg.Out == 1 || !createdAt.After(since) {
+			continue
+		}
+		title := "Новое сообщение"
+		if it.Conversation.UnreadCount > 1 {
+			title = fmt.Sprintf("Новых сообщений: %d", it.Conversation.UnreadCount)
+		}
+		updates = append(updates, model.Update{
+			Type:      TypeMessage,
+			Title:     title,
+			Author:    pageName(msg.FromID),
+			URL:       fmt.Sprintf("%s/im?sel=%d", siteURL, it.Conversation.Peer.ID),
+			Preview:   clients.Preview(msg.Text),
+			CreatedAt: createdAt,
+		})
+	}
+	return updates, nil
+}
+
+type wall struct {
+	Items []struct {
+		ID      int    `json:"id"`
+		OwnerID int    `json:"owner_id"`
+		FromID  int    `json:"from_id"`
+		Date    int64  `json:"date"`
+		Text    string `json:"text"`
+		Likes   struct {
+			Count int `json:"count"`
+		} `json:"likes"`
+	} `json:"items"`
+}
+
+func (c *Client) checkWall(ctx context.Context, t *target, since time.Time, token string) ([]model.Update, error) {
+	params := url.Values{"count": {"20"}}
+	if t.ownerID != 0 {
+		params.Set("owner_id", strconv.Itoa(t.ownerID))
+	} else {
+		params.Set("domain", t.domain)
+	}
+
+	var resp wall
+	if err := c.call(ctx, "wall.get", params, token, &resp); err != nil {
+		return nil, err
+	}
+
+	var updates []model.Update
+	for _, post := range resp.Items {
+		createdAt := time.Unix(post.Date, 0).UTC()
+		if !createdAt.After(since) {
+			continue
+		}
+		updates = append(updates, model.Update{
+			Type:      TypePost,
+			Author:    pageName(post.FromID),
+			URL:       fmt.Sprintf("%s/wall%d_%d", siteURL, post.OwnerID, post.ID),
+			Preview:   clients.Preview(post.Text),
+			Score:     post.Likes.Count,
+			CreatedAt: createdAt,
+		})
+	}
+	return updates, nil
+}
+
+type requests struct {
+	Count int   `json:"count"`
+	Items []int `json:"items"`
+}
+
+// checkFriendRequests сравнивает входящие заявки с запомненными в link.State.
+// Дата заявки API не возвращает, поэтому новые определяются по id
+func (c *Client) checkFriendRequests(ctx context.Context, link *model.Link, token string) ([]model.Update, error) {
+	var resp requests
+	err := c.call(ctx, "friends.getRequests", url.Values{"count": {"1000"}}, token, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev state
+	firstCheck := len(link.State) == 0
+	if !firstCheck {
+		if err := json.Unmarshal(link.State, &prev); err != nil {
+			return nil, fmt.Errorf("vk: bad link state: %w", err)
+		}
+	}
+
+	var updates []model.Update
+	if !firstCheck {
+		now := time.Now().UTC()
+		for _, id := range resp.Items {
+			if slices.Contains(prev.Requests, id) {
+				continue
+			}
+			updates = append(updates, model.Update{
+				Type:      TypeFriendRequest,
+				Title:     "Новая заявка в друзья",
+				Author:    pageName(id),
+				URL:       fmt.Sprintf("%s/id%d", siteURL, id),
+				CreatedAt: now,
+			})
+		}
+	}
+
+	newState, err := json.Marshal(state{Requests: resp.Items})
+	if err != nil {
+		return nil, err
+	}
+	link.State = newState
+	return updates, nil
+}
+
+type apiError struct {
+	Code    int    `json:"error_code"`
+	Message string `json:"error_msg"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("vk: error %d: %s", e.Code, e.Message)
+}
+
+// call вызывает метод VK API и декодирует поле response в out.
+// Методы вызываются POST-запросом, поэтому ETag и If-Modified-Since здесь не применимы
+func (c *Client) call(ctx context.Context, method string, params url.Values, token string, out any) error {
+	params.Set("access_token", token)
+	params.Set("v", c.version)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/method/"+method,
+		strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vk: unexpected status %d for %s", resp.StatusCode, method)
+	}
+
+	var body struct {
+		Response json.RawMessage `json:"response"`
+		Error    *apiError       `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	if body.Error != nil {
+		return body.Error
+	}
+	return json.Unmarshal(body.Response, out)
+}
+
+// pageName превращает id пользователя или сообщества в имя страницы
+func pageName(id int) string {
+	if id < 0 {
+		return fmt.Sprintf("club%d", -id)
+	}
+	return fmt.Sprintf("id%d", id)
+}
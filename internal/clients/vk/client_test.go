@@ -0,0 +1,210 @@
+package vk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokens map[int]string
+
+func (f fakeTokens) GetToken(_ context.Context, id int) (string, error) {
+	token, ok := f[id]
+	if !ok {
+		return "", errors.New("no such token")
+	}
+	return token, nil
+}
+
+// 2025-05-01 12:00:00 UTC
+var since = time.Unix(1746100800, 0).UTC()
+
+// newFakeVK поднимает заглушку VK API
+func newFakeVK(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("access_token") != "secret" {
+			w.Write([]byte(`{"error": {"error_code": 5, "error_msg": "User authorization failed"}}`))
+			return
+		}
+		assert.Equal(t, "5.199", r.PostForm.Get("v"))
+
+		switch r.URL.Path {
+		case "/method/messages.getConversations":
+			w.Write([]byte(`{"response": {"count": 3, "items": [
+				{"conversation": {"peer": {"id": 100}, "unread_count": 2},
+				 "last_message": {"id": 11, "date": 1746104400, "from_id": 100, "peer_id": 100, "out": 0, "text": "Привет!"}},
+				{"conversation": {"peer": {"id": 200}},
+				 "last_message": {"id": 12, "date": 1746104400, "from_id": 1, "peer_id": 200, "out": 1, "text": "my own"}},
+				{"conversation": {"peer": {"id": 300}},
+				 "last_message": {"id": 13, "date": 1746000000, "from_id": 300, "peer_id": 300, "out": 0, "text": "old"}}
+			]}}`))
+		case "/method/wall.get":
+			assert.Equal(t, "-42", r.PostForm.Get("owner_id"))
+			w.Write([]byte(`{"response": {"count": 2, "items": [
+				{"id": 7, "owner_id": -42, "from_id": -42, "date": 1746104400, "text": "Анонс", "likes": {"count": 10}},
+				{"id": 6, "owner_id": -42, "from_id": -42, "date": 1746000000, "text": "old"}
+			]}}`))
+		case "/method/friends.getRequests":
+			w.Write([]byte(`{"response": {"count": 2, "items": [5, 9]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		link    string
+		want    *target
+		wantErr bool
+	}{
+		{link: "https://vk.com/im", want: &target{mode: ModeDialogs}},
+		{link: "vk.com/im?sel=100", want: &target{mode: ModeDialogs}},
+		{link: "https://vk.com/friends?section=requests", want: &target{mode: ModeFriendRequests}},
+		{link: "https://vk.com/club42", want: &target{mode: ModeWall, ownerID: -42}},
+		{link: "https://vk.com/public42", want: &target{mode: ModeWall, ownerID: -42}},
+		{link: "https://vk.com/id7", want: &target{mode: ModeWall, ownerID: 7}},
+		{link: "https://vk.com/golang_news", want: &target{mode: ModeWall, domain: "golang_news"}},
+		{link: "https://vk.com/friends", wantErr: true},
+		{link: "https://vk.com/", wantErr: true},
+		{link: "https://vk.com/wall-42_7/extra", wantErr: true},
+		{link: "https://vk.com/wall-42_7", wantErr: true},
+		{link: "https://vk.com/feed", wantErr: true},
+		{link: "https://m.vk.com/settings", wantErr: true},
+		{link: "https://vk.com/Music", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			got, err := parseLink(tt.link)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckDialogs(t *testing.T) {
+	srv := newFakeVK(t)
+	c := NewClient(srv.URL, "5.199", srv.Client(), fakeTokens{1: "secret"})
+
+	tokenID := 1
+	link := &model.Link{ID: 1, Link: "https://vk.com/im", TokenID: &tokenID, LastCheckedAt: &since}
+	updates, err := c.Check(context.Background(), link)
+	require.NoError(t, err)
+
+	assert.Equal(t, []model.Update{{
+		Type:      TypeMessage,
+		Title:     "Новых сообщений: 2",
+		Author:    "id100",
+		URL:       "https://vk.com/im?sel=100",
+		Preview:   "Привет!",
+		CreatedAt: time.Unix(1746104400, 0).UTC(),
+	}}, updates)
+}
+
+func TestCheckWall(t *testing.T) {
+	srv := newFakeVK(t)
+	c := NewClient(srv.URL, "5.199", srv.Client(), fakeTokens{1: "secret"})
+
+	tokenID := 1
+	link := &model.Link{ID: 1, Link: "https://vk.com/club42", TokenID: &tokenID, LastCheckedAt: &since}
+	updates, err := c.Check(context.Background(), link)
+	require.NoError(t, err)
+
+	assert.Equal(t, []model.Update{{
+		Type:      TypePost,
+		Author:    "club42",
+		URL:       "https://vk.com/wall-42_7",
+		Preview:   "Анонс",
+		Score:     10,
+		CreatedAt: time.Unix(1746104400, 0).UTC(),
+	}}, updates)
+}
+
+func TestCheckFriendRequests(t *testing.T) {
+	srv := newFakeVK(t)
+	c := NewClient(srv.URL, "5.199", srv.Client(), fakeTokens{1: "secret"})
+	tokenID := 1
+
+	t.Run("first check remembers requests", func(t *testing.T) {
+		link := &model.Link{ID: 1, Link: "https://vk.com/friends?section=requests", TokenID: &tokenID}
+		updates, err := c.Check(context.Background(), link)
+
+		require.NoError(t, err)
+		assert.Empty(t, updates)
+		assert.JSONEq(t, `{"requests": [5, 9]}`, string(link.State))
+	})
+
+	t.Run("only unseen requests are reported", func(t *testing.T) {
+		link := &model.Link{
+			ID: 1, Link: "https://vk.com/friends?section=requests", TokenID: &tokenID,
+			LastCheckedAt: &since, State: []byte(`{"requests": [5]}`),
+		}
+		updates, err := c.Check(context.Background(), link)
+
+		require.NoError(t, err)
+		require.Len(t, updates, 1)
+		assert.Equal(t, TypeFriendRequest, updates[0].Type)
+		assert.Equal(t, "https://vk.com/id9", updates[0].URL)
+		assert.JSONEq(t, `{"requests": [5, 9]}`, string(link.State))
+	})
+}
+
+func TestCheckErrors(t *testing.T) {
+	srv := newFakeVK(t)
+	tokenID := 1
+
+	t.Run("no token", func(t *testing.T) {
+		c := NewClient(srv.URL, "5.199", srv.Client(), fakeTokens{})
+		_, err := c.Check(context.Background(), &model.Link{ID: 1, Link: "https://vk.com/im"})
+		assert.ErrorIs(t, err, ErrNoToken)
+	})
+
+	t.Run("token unavailable", func(t *testing.T) {
+		c := NewClient(srv.URL, "5.199", srv.Client(), fakeTokens{})
+		_, err := c.Check(context.Background(), &model.Link{ID: 1, Link: "https://vk.com/im", TokenID: &tokenID})
+		assert.ErrorContains(t, err, "no such token")
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		c := NewClient(srv.URL, "5.199", srv.Client(), fakeTokens{1: "revoked"})
+		_, err := c.Check(context.Background(), &model.Link{ID: 1, Link: "https://vk.com/im", TokenID: &tokenID, LastCheckedAt: &since})
+		assert.EqualError(t, err, "vk: error 5: User authorization failed")
+	})
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{link: "https://vk.com/club123", want: "https://vk.com/club123"},
+		{link: "https://m.vk.com/Durov?from=search", want: "https://vk.com/durov"},
+		{link: "vk.com/im?sel=5", want: "https://vk.com/im"},
+		{link: "https://vk.com/friends?section=requests&w=1", want: "https://vk.com/friends?section=requests"},
+	}
+
+	c := new(Client)
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			u, err := clients.ParseURL(tt.link)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.Canonical(u))
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package webpage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+	"golang.org/x/net/html"
+)
+
+const (
+	// Kind - тип источника для страниц без API
+	Kind = "html"
+
+	TypeChange = "change"
+
+	maxPageSize = 5 << 20
+	// текст длиннее этого не сохраняется целиком, дифф строится по обрезанному
+	maxStoredText = 64 << 10
+)
+
+// ErrInvalidSelector - CSS-селектор не разбирается
+var ErrInvalidSelector = errors.New("invalid selector")
+
+// truncateText обрезает текст до limit байт по границе последней целой строки.
+// Если строка одна (минифицированная страница), режет по границе UTF-8 символа
+func truncateText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	if i := strings.LastIndexByte(text[:limit], '\n'); i >= 0 {
+		return text[:i]
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
+}
+
+// ValidateSelector проверяет, что CSS-селектор корректен
+func ValidateSelector(selector string) error {
+	if selector == "" {
+		return nil
+	}
+	_, err := cascadia.ParseGroup(selector)
+	if err != nil {
+		return fmt.Errorf("%w %q: %w", ErrInvalidSelector, selector, err)
+	}
+	return nil
+}
+
+// Client отслеживает изменения текста на произвольных страницах
+type Client struct {
+	http *http.Client
+}
+
+// NewClient создаёт клиент для страниц
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{http: httpClient}
+}
+
+func (c *Client) Kind() string {
+	return Kind
+}
+
+// Probe сообщает, отдаёт ли ссылка HTML-страницу
+func (c *Client) Probe(ctx context.Context, link string) bool {
+	contentType, ok := clients.ProbeContentType(ctx, c.http, link)
+	if !ok {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// state - хэш и текст страницы на момент предыдущей проверки
+type state struct {
+	Hash string `json:"hash"`
+	Text string `json:"text"`
+}
+
+// Check сравнивает текст страницы (или её части по link.Selector)
+// с предыдущей проверкой и возвращает дифф, если текст изменился.
+// При первой проверке событий нет - запоминается только текст
+func (c *Client) Check(ctx context.Context, link *model.Link) ([]model.Update, error) {
+	doc, err := c.fetch(ctx, link)
+	if err != nil || doc == nil {
+		// страница не изменилась - состояние оставляем как есть
+		return nil, err
+	}
+
+	text, err := extract(doc, link.Selector)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(text))
+	hash := hex.EncodeToString(sum[:])
+
+	var prev state
+	if len(link.State) > 0 {
+		if err := json.Unmarshal(link.State, &prev); err != nil {
+			return nil, fmt.Errorf("webpage: bad link state: %w", err)
+		}
+	}
+	if prev.Hash == hash {
+		return nil, nil
+	}
+
+	stored := truncateText(text, maxStoredText)
+	newState, err := json.Marshal(state{Hash: hash, Text: stored})
+	if err != nil {
+		return nil, err
+	}
+	link.State = newState
+
+	if prev.Hash == "" {
+		return nil, nil
+	}
+
+	diff := diffLines(splitLines(prev.Text), splitLines(stored))
+	return []model.Update{{
+		Type:      TypeChange,
+		Title:     title(doc),
+		URL:       link.Link,
+		Preview:   clients.Truncate(strings.Join(diff, "\n")),
+		CreatedAt: time.Now().UTC(),
+	}}, nil
+}
+
+// fetch загружает страницу. Если она не изменилась с прошлой проверки (304), возвращает nil
+func (c *Client) fetch(ctx context.Context, link *model.Link) (*html.Node, error) {
+	u, err := clients.ParseURL(link.Link)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+
+	resp, err := clients.Do(c.http, req, link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webpage: unexpected status %d for %s", resp.StatusCode, link.Link)
+	}
+	return html.Parse(io.LimitReader(resp.Body, maxPageSize))
+}
+
+// extract возвращает нормализованный текст страницы или выбранных селектором элементов
+func extract(doc *html.Node, selector string) (string, error) {
+	nodes := []*html.Node{doc}
+	if selector != "" {
+		sel, err := cascadia.ParseGroup(selector)
+		if err != nil {
+			return "", fmt.Errorf("webpage: invalid selector %q: %w", selector, err)
+		}
+		nodes = cascadia.QueryAll(doc, sel)
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		writeText(&b, n)
+		b.WriteByte('\n')
+	}
+	return normalize(b.String()), nil
+}
+
+// блочные элементы начинают новую строку, чтобы дифф был построчным
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "article": true, "section": true,
+	"header": true, "footer": true, "pre": true, "blockquote": true, "table": true, "ul": true, "ol": true,
+}
+
+func writeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "style", "noscript", "template", "head":
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blockElements[n.Data]
+	if block {
+		b.WriteByte('\n')
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeText(b, child)
+	}
+	if block {
+		b.WriteByte('\n')
+	}
+}
+
+// normalize схлопывает пробелы внутри строк и убирает пустые строки
+func normalize(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+var titleSelector = cascadia.MustCompile("title")
+
+func title(doc *html.Node) string {
+	if n := cascadia.Query(doc, titleSelector); n != nil && n.FirstChild != nil {
+		return strings.TrimSpace(n.FirstChild.Data)
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package webpage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pageV1 = `<html>
+<head><title> Status </title><style>.x{}</style></head>
+<body>
+  <div id="banner">Ad   #1</div>
+  <ul id="news">
+    <li>Release   1.0</li>
+    <li>Maintenance window</li>
+  </ul>
+  <script>var t = Date.now()</script>
+</body>
+</html>`
+
+const pageV2 = `<html>
+<head><title> Status </title></head>
+<body>
+  <div id="banner">Ad #2</div>
+  <ul id="news">
+    <li>Release 1.1</li>
+    <li>Release   1.0</li>
+  </ul>
+</body>
+</html>`
+
+func TestValidateSelector(t *testing.T) {
+	assert.NoError(t, ValidateSelector(""))
+	assert.NoError(t, ValidateSelector("#news > li, .changelog"))
+	assert.Error(t, ValidateSelector("div >"))
+}
+
+func TestDiffLines(t *testing.T) {
+	old := []string{"a", "b", "c", "d"}
+	new := []string{"a", "c", "x", "d", "e"}
+
+	assert.Equal(t, []string{"- b", "+ x", "+ e"}, diffLines(old, new))
+	assert.Empty(t, diffLines(old, old))
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{name: "short", text: "abc", limit: 5, want: "abc"},
+		{name: "cut at line", text: "one\ntwo\nthree", limit: 10, want: "one\ntwo"},
+		{name: "single line", text: "abcdefgh", limit: 5, want: "abcde"},
+		{name: "single line keeps runes whole", text: "абвгд", limit: 5, want: "аб"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, truncateText(tt.text, tt.limit))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	page := pageV1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+	c := NewClient(srv.Client())
+
+	tests := []struct {
+		name     string
+		selector string
+		wantText string
+		wantDiff string
+	}{
+		{
+			name:     "whole page",
+			wantText: "Ad #1\nRelease 1.0\nMaintenance window",
+			wantDiff: "- Ad #1\n+ Ad #2\n+ Release 1.1\n- Maintenance window",
+		},
+		{
+			name:     "selector narrows the page",
+			selector: "#news > li",
+			wantText: "Release 1.0\nMaintenance window",
+			wantDiff: "+ Release 1.1\n- Maintenance window",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page = pageV1
+			link := &model.Link{ID: 1, Link: srv.URL, Kind: Kind, Selector: tt.selector}
+
+			// первая проверка только запоминает текст
+			updates, err := c.Check(context.Background(), link)
+			require.NoError(t, err)
+			assert.Empty(t, updates)
+			assert.Contains(t, string(link.State), `"text":`)
+
+			// страница не изменилась
+			updates, err = c.Check(context.Background(), link)
+			require.NoError(t, err)
+			assert.Empty(t, updates)
+
+			page = pageV2
+			updates, err = c.Check(context.Background(), link)
+			require.NoError(t, err)
+			require.Len(t, updates, 1)
+			assert.Equal(t, TypeChange, updates[0].Type)
+			assert.Equal(t, "Status", updates[0].Title)
+			assert.Equal(t, srv.URL, updates[0].URL)
+			assert.Equal(t, tt.wantDiff, updates[0].Preview)
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pageV1))
+	}))
+	defer srv.Close()
+
+	doc, err := NewClient(srv.Client()).fetch(context.Background(), &model.Link{Link: srv.URL})
+	require.NoError(t, err)
+
+	text, err := extract(doc, "")
+	require.NoError(t, err)
+	assert.Equal(t, "Ad #1\nRelease 1.0\nMaintenance window", text)
+
+	text, err = extract(doc, "#news li:first-child")
+	require.NoError(t, err)
+	assert.Equal(t, "Release 1.0", text)
+}
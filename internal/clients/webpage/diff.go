@@ -0,0 +1,75 @@
+package webpage
+
+// после этого размера LCS становится слишком дорогим,
+// и дифф строится как разность множеств строк
+const maxDiffLines = 2000
+
+// diffLines возвращает построчный дифф: удалённые строки с префиксом "- ",
+// добавленные - с "+ ", в порядке появления
+func diffLines(old, new []string) []string {
+	if len(old) > maxDiffLines || len(new) > maxDiffLines {
+		return setDiff(old, new)
+	}
+
+	// lcs[i][j] - длина наибольшей общей подпоследовательности old[i:] и new[j:]
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+old[i])
+			i++
+		default:
+			diff = append(diff, "+ "+new[j])
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		diff = append(diff, "- "+old[i])
+	}
+	for ; j < len(new); j++ {
+		diff = append(diff, "+ "+new[j])
+	}
+	return diff
+}
+
+func setDiff(old, new []string) []string {
+	oldSet := make(map[string]bool, len(old))
+	for _, line := range old {
+		oldSet[line] = true
+	}
+	newSet := make(map[string]bool, len(new))
+	for _, line := range new {
+		newSet[line] = true
+	}
+
+	var diff []string
+	for _, line := range old {
+		if !newSet[line] {
+			diff = append(diff, "- "+line)
+		}
+	}
+	for _, line := range new {
+		if !oldSet[line] {
+			diff = append(diff, "+ "+line)
+		}
+	}
+	return diff
+}
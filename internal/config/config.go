@@ -3,13 +3,38 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	ServerAddr string
-	DB         DBConfig
+	// сколько может обрабатываться HTTP-запрос, включая запросы к БД; 0 - без ограничения
+	RequestTimeout time.Duration
+	DB             DBConfig
+	Scheduler      SchedulerConfig
+	Outbox         OutboxConfig
+	RateLimit      RateLimitConfig
+	GitHub         GitHubConfig
+	StackOverflow  StackOverflowConfig
+	Reddit         RedditConfig
+	VK             VKConfig
+	// куда отправлять уведомления: "bot" - в сервис бота по HTTP,
+	// "telegram" - напрямую в Bot API. "queue" - в топик встроенного брокера:
+	// читать его можно только внутри процесса, поэтому режим годится лишь для тестов
+	Delivery string
+	Bot      BotConfig
+	Telegram TelegramConfig
+	Queue    QueueConfig
+	Tokens   TokensConfig
+
+	// токен для маршрутов /admin и /debug; пустой - они закрыты
+	AdminToken string
+	// разрешить запросы к частным и локальным адресам; только для разработки
+	AllowPrivateHosts bool
 }
 
 type DBConfig struct {
@@ -17,6 +42,99 @@ type DBConfig struct {
 	User     string
 	Password string
 	DBName   string
+	// применять новые миграции при запуске сервиса
+	AutoMigrate bool
+}
+
+type SchedulerConfig struct {
+	// как часто запускать проверку ссылок
+	Interval time.Duration
+	// сколько ссылок загружать из БД за один запрос
+	BatchSize int
+}
+
+type OutboxConfig struct {
+	// как часто проверять outbox
+	Interval  time.Duration
+	BatchSize int
+	// пауза перед первым повтором, дальше удваивается до MaxRetryDelay
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// на сколько забранная порция скрывается от других экземпляров
+	Lease time.Duration
+	// предельное время одной отправки. За раз забирается не больше
+	// Lease / SendTimeout уведомлений, чтобы lease не истёк посреди порции
+	SendTimeout time.Duration
+	// после стольких неудачных попыток уведомление уходит в dead_letters
+	MaxAttempts int
+}
+
+// HostLimit - ограничение запросов к хосту: RPS в среднем, Burst подряд
+type HostLimit struct {
+	RPS   float64
+	Burst int
+}
+
+type RateLimitConfig struct {
+	// ограничение для хостов, которых нет в Hosts; RPS <= 0 - без ограничения
+	Default HostLimit
+	// задаётся как RATE_LIMIT_HOSTS=api.github.com=1.2:10,www.reddit.com=0.5:3
+	Hosts map[string]HostLimit
+}
+
+type GitHubConfig struct {
+	BaseURL string
+}
+
+type StackOverflowConfig struct {
+	BaseURL string
+	// ключ приложения StackExchange, необязателен, но увеличивает квоту
+	Key string
+}
+
+type RedditConfig struct {
+	// публичные JSON-эндпоинты
+	BaseURL string
+	// эндпоинты для запросов с OAuth-токеном
+	OAuthURL string
+	// Reddit блокирует запросы без осмысленного User-Agent
+	UserAgent string
+}
+
+type VKConfig struct {
+	BaseURL string
+	// версия VK API, передаётся в каждом запросе
+	Version string
+}
+
+type BotConfig struct {
+	// адрес сервиса бота, куда отправляются обновления
+	URL     string
+	Timeout time.Duration
+}
+
+type TelegramConfig struct {
+	BaseURL string
+	Token   string
+}
+
+type QueueConfig struct {
+	// реализация брокера; пока поддерживается только встроенный "memory"
+	Broker          string
+	Topic           string
+	DeadLetterTopic string
+	// разрешить встроенный брокер: он не переживает перезапуск
+	// и годится только для разработки и тестов
+	AllowMemory bool
+	// сколько сообщений встроенный брокер хранит в топике без подписчиков
+	MemoryCapacity int
+}
+
+type TokensConfig struct {
+	// ключ AES-256 в base64 для шифрования токенов доступа
+	Key string
+	// прежние ключи через запятую: нужны, пока токены не перешифрованы новым ключом
+	OldKeys []string
 }
 
 // Load загружает конфигурацию из .env или окружения
@@ -26,12 +144,73 @@ func Load() *Config {
 	}
 
 	return &Config{
-		ServerAddr: getEnv("SERVER_ADDR", ":8080"),
+		ServerAddr:     getEnv("SERVER_ADDR", ":8080"),
+		RequestTimeout: getEnvDuration("SERVER_REQUEST_TIMEOUT", 10*time.Second),
+		AdminToken:     getEnv("ADMIN_TOKEN", ""),
+		// ссылки пользователей не должны вести во внутреннюю сеть сервиса
+		AllowPrivateHosts: getEnvBool("ALLOW_PRIVATE_HOSTS", false),
 		DB: DBConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "password"),
-			DBName:   getEnv("DB_NAME", "mydb"),
+			Host:        getEnv("DB_HOST", "localhost"),
+			User:        getEnv("DB_USER", "postgres"),
+			Password:    getEnv("DB_PASSWORD", "password"),
+			DBName:      getEnv("DB_NAME", "mydb"),
+			AutoMigrate: getEnvBool("DB_AUTO_MIGRATE", true),
+		},
+		Scheduler: SchedulerConfig{
+			Interval:  getEnvDuration("SCHEDULER_INTERVAL", time.Minute),
+			BatchSize: getEnvInt("SCHEDULER_BATCH_SIZE", 100),
+		},
+		Outbox: OutboxConfig{
+			Interval:      getEnvDuration("OUTBOX_INTERVAL", time.Second),
+			BatchSize:     getEnvInt("OUTBOX_BATCH_SIZE", 100),
+			RetryDelay:    getEnvDuration("OUTBOX_RETRY_DELAY", 5*time.Second),
+			MaxRetryDelay: getEnvDuration("OUTBOX_MAX_RETRY_DELAY", 10*time.Minute),
+			Lease:         getEnvDuration("OUTBOX_LEASE", 5*time.Minute),
+			SendTimeout:   getEnvDuration("OUTBOX_SEND_TIMEOUT", 30*time.Second),
+			MaxAttempts:   getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+		},
+		RateLimit: RateLimitConfig{
+			Default: HostLimit{
+				RPS:   getEnvFloat("RATE_LIMIT_RPS", 1),
+				Burst: getEnvInt("RATE_LIMIT_BURST", 5),
+			},
+			Hosts: getEnvHostLimits("RATE_LIMIT_HOSTS"),
+		},
+		GitHub: GitHubConfig{
+			BaseURL: getEnv("GITHUB_API_URL", "https://api.github.com"),
+		},
+		StackOverflow: StackOverflowConfig{
+			BaseURL: getEnv("STACKOVERFLOW_API_URL", "https://api.stackexchange.com/2.3"),
+			Key:     getEnv("STACKOVERFLOW_KEY", ""),
+		},
+		Reddit: RedditConfig{
+			BaseURL:   getEnv("REDDIT_URL", "https://www.reddit.com"),
+			OAuthURL:  getEnv("REDDIT_OAUTH_URL", "https://oauth.reddit.com"),
+			UserAgent: getEnv("REDDIT_USER_AGENT", "scraptor/0.1"),
+		},
+		VK: VKConfig{
+			BaseURL: getEnv("VK_API_URL", "https://api.vk.com"),
+			Version: getEnv("VK_API_VERSION", "5.199"),
+		},
+		Delivery: getEnv("DELIVERY_MODE", "bot"),
+		Bot: BotConfig{
+			URL:     getEnv("BOT_URL", "http://localhost:8090"),
+			Timeout: getEnvDuration("BOT_TIMEOUT", 5*time.Second),
+		},
+		Telegram: TelegramConfig{
+			BaseURL: getEnv("TELEGRAM_API_URL", "https://api.telegram.org"),
+			Token:   getEnv("TELEGRAM_TOKEN", ""),
+		},
+		Queue: QueueConfig{
+			Broker:          getEnv("QUEUE_BROKER", "memory"),
+			Topic:           getEnv("QUEUE_TOPIC", "link-updates"),
+			DeadLetterTopic: getEnv("QUEUE_DLQ_TOPIC", "link-updates-dlq"),
+			AllowMemory:     getEnvBool("QUEUE_ALLOW_MEMORY", false),
+			MemoryCapacity:  getEnvInt("QUEUE_MEMORY_CAPACITY", 1000),
+		},
+		Tokens: TokensConfig{
+			Key:     getEnv("TOKENS_KEY", ""),
+			OldKeys: getEnvList("TOKENS_OLD_KEYS"),
 		},
 	}
 }
@@ -42,3 +221,108 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value of %s: %q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value of %s: %q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value of %s: %q, using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value of %s: %q, using default %g", key, value, defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+// getEnvList разбирает список через запятую, пустые элементы пропускаются
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvHostLimits разбирает список вида host=rps:burst через запятую.
+// Некорректные элементы пропускаются
+func getEnvHostLimits(key string) map[string]HostLimit {
+	limits := make(map[string]HostLimit)
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return limits
+	}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		host, limit, ok := parseHostLimit(item)
+		if !ok {
+			log.Printf("Invalid item of %s: %q, skipping", key, item)
+			continue
+		}
+		limits[host] = limit
+	}
+	return limits
+}
+
+func parseHostLimit(item string) (string, HostLimit, bool) {
+	host, spec, ok := strings.Cut(item, "=")
+	if !ok || host == "" {
+		return "", HostLimit{}, false
+	}
+	rps, burst, ok := strings.Cut(spec, ":")
+	if !ok {
+		return "", HostLimit{}, false
+	}
+	var limit HostLimit
+	var err1, err2 error
+	limit.RPS, err1 = strconv.ParseFloat(rps, 64)
+	limit.Burst, err2 = strconv.Atoi(burst)
+	if err1 != nil || err2 != nil {
+		return "", HostLimit{}, false
+	}
+	return strings.ToLower(host), limit, true
+}
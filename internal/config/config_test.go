@@ -0,0 +1,18 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEnvHostLimits(t *testing.T) {
+	t.Setenv("RATE_LIMIT_HOSTS", "API.GitHub.com=1.5:10, www.reddit.com=0.5:3,broken,bad=x:1")
+
+	assert.Equal(t, map[string]HostLimit{
+		"api.github.com": {RPS: 1.5, Burst: 10},
+		"www.reddit.com": {RPS: 0.5, Burst: 3},
+	}, getEnvHostLimits("RATE_LIMIT_HOSTS"))
+
+	assert.Empty(t, getEnvHostLimits("RATE_LIMIT_UNSET"))
+}
@@ -0,0 +1,128 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+// ErrInvalidFilter - фильтр записан с ошибкой
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Поддерживаемые виды фильтров
+const (
+	// user:alice - автор события
+	KindUser = "user"
+	// type:comment - тип события
+	KindType = "type"
+	// contains:release - подстрока в заголовке или тексте события, без учёта регистра
+	KindContains = "contains"
+	// regex:^v\d+ - регулярное выражение по заголовку и тексту события
+	KindRegex = "regex"
+)
+
+// Filter - одно условие на событие. Negate - условие записано с минусом
+// и событие, подходящее под него, отбрасывается
+type Filter struct {
+	Kind   string
+	Value  string
+	Negate bool
+	re     *regexp.Regexp
+}
+
+// Set - фильтры ссылки в чате.
+// Событие проходит, если не подходит ни под один фильтр с минусом и
+// подходит хотя бы под один фильтр каждого вида без минуса
+type Set []Filter
+
+// Parse разбирает фильтр вида [-]kind:value
+func Parse(raw string) (Filter, error) {
+	var f Filter
+	s := strings.TrimSpace(raw)
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		f.Negate = true
+		s = rest
+	}
+
+	kind, value, ok := strings.Cut(s, ":")
+	if !ok || value == "" {
+		return Filter{}, fmt.Errorf("%w %q: expected kind:value", ErrInvalidFilter, raw)
+	}
+	f.Kind, f.Value = strings.ToLower(kind), value
+
+	switch f.Kind {
+	case KindUser, KindType:
+	case KindContains:
+		f.Value = strings.ToLower(value)
+	case KindRegex:
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return Filter{}, fmt.Errorf("%w %q: %w", ErrInvalidFilter, raw, err)
+		}
+		f.re = re
+	default:
+		return Filter{}, fmt.Errorf("%w %q: unknown kind %q", ErrInvalidFilter, raw, kind)
+	}
+	return f, nil
+}
+
+// ParseAll разбирает фильтры ссылки
+func ParseAll(raw []string) (Set, error) {
+	set := make(Set, 0, len(raw))
+	var errs []error
+	for _, r := range raw {
+		f, err := Parse(r)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		set = append(set, f)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return set, nil
+}
+
+// Match проверяет, проходит ли событие через фильтры. Пустой набор пропускает всё
+func (s Set) Match(u model.Update) bool {
+	// для каждого вида без минуса - нашлось ли совпадение
+	required := make(map[string]bool)
+	for _, f := range s {
+		matched := f.match(u)
+		if f.Negate {
+			if matched {
+				return false
+			}
+			continue
+		}
+		required[f.Kind] = required[f.Kind] || matched
+	}
+	for _, ok := range required {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filter) match(u model.Update) bool {
+	switch f.Kind {
+	case KindUser:
+		return strings.EqualFold(u.Author, f.Value)
+	case KindType:
+		return strings.EqualFold(u.Type, f.Value)
+	case KindContains:
+		return strings.Contains(strings.ToLower(text(u)), f.Value)
+	case KindRegex:
+		return f.re.MatchString(text(u))
+	}
+	return false
+}
+
+func text(u model.Update) string {
+	return u.Title + "\n" + u.Preview
+}
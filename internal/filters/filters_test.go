@@ -0,0 +1,74 @@
+package filters
+
+import (
+	"testing"
+
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Filter
+		wantErr bool
+	}{
+		{raw: "user:alice", want: Filter{Kind: KindUser, Value: "alice"}},
+		{raw: " -user:dependabot[bot] ", want: Filter{Kind: KindUser, Value: "dependabot[bot]", Negate: true}},
+		{raw: "Contains:Release", want: Filter{Kind: KindContains, Value: "release"}},
+		{raw: "type:comment", want: Filter{Kind: KindType, Value: "comment"}},
+		{raw: "user:", wantErr: true},
+		{raw: "alice", wantErr: true},
+		{raw: "author:alice", wantErr: true},
+		{raw: "regex:(", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := Parse(tt.raw)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidFilter)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	release := model.Update{Type: "release", Title: "v1.2.0", Author: "alice", Preview: "Release notes"}
+	botComment := model.Update{Type: "comment", Author: "renovate[bot]", Preview: "Update dependency"}
+	comment := model.Update{Type: "comment", Author: "bob", Preview: "LGTM"}
+
+	tests := []struct {
+		name    string
+		filters []string
+		want    []bool
+	}{
+		{name: "no filters", want: []bool{true, true, true}},
+		{name: "exclude bot", filters: []string{"-user:renovate[bot]"}, want: []bool{true, false, true}},
+		{name: "users are or-ed", filters: []string{"user:alice", "user:BOB"}, want: []bool{true, false, true}},
+		{name: "kinds are and-ed", filters: []string{"type:comment", "contains:lgtm"}, want: []bool{false, false, true}},
+		{name: "regex", filters: []string{`regex:^v\d+\.\d+`}, want: []bool{true, false, false}},
+		{name: "negated regex", filters: []string{`-regex:(?i)dependency`}, want: []bool{true, false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ParseAll(tt.filters)
+			require.NoError(t, err)
+
+			got := []bool{set.Match(release), set.Match(botComment), set.Match(comment)}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	_, err := ParseAll([]string{"user:alice", "foo", "regex:["})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+	assert.ErrorContains(t, err, `"foo"`)
+	assert.ErrorContains(t, err, `"regex:["`)
+}
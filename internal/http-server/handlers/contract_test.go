@@ -0,0 +1,359 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/http-server/handlers"
+	slogpretty "github.com/grigory222/scraptor/internal/logger"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// Контрактные тесты: ответы настоящих хендлеров сверяются с docs/openapi.yml
+
+const specPath = "../../../docs/openapi.yml"
+
+type spec struct {
+	Paths      map[string]map[string]operation `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*schema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type operation struct {
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema *schema `yaml:"schema"`
+		} `yaml:"content"`
+	} `yaml:"responses"`
+}
+
+// schema - подмножество JSON Schema, которого хватает для нашей спецификации
+type schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       any                `yaml:"type"`
+	Properties map[string]*schema `yaml:"properties"`
+	Required   []string           `yaml:"required"`
+	Items      *schema            `yaml:"items"`
+	Enum       []any              `yaml:"enum"`
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+	data, err := os.ReadFile(specPath)
+	require.NoError(t, err)
+	var s spec
+	require.NoError(t, yaml.Unmarshal(data, &s))
+	return &s
+}
+
+func (s *spec) resolve(sc *schema) *schema {
+	for sc != nil && sc.Ref != "" {
+		sc = s.Components.Schemas[strings.TrimPrefix(sc.Ref, "#/components/schemas/")]
+	}
+	return sc
+}
+
+// validate проверяет значение по схеме. Свойства, которых нет в схеме, считаются ошибкой,
+// чтобы новое поле в ответе не появлялось без описания в спецификации
+func (s *spec) validate(sc *schema, v any, path string) []error {
+	sc = s.resolve(sc)
+	if sc == nil {
+		return []error{fmt.Errorf("%s: unknown schema", path)}
+	}
+
+	types := []string{}
+	switch t := sc.Type.(type) {
+	case string:
+		types = append(types, t)
+	case []any:
+		for _, item := range t {
+			types = append(types, fmt.Sprint(item))
+		}
+	}
+	if len(types) > 0 && !slices.Contains(types, jsonType(v)) &&
+		!(jsonType(v) == "integer" && slices.Contains(types, "number")) {
+		return []error{fmt.Errorf("%s: got %s, want %v", path, jsonType(v), types)}
+	}
+	if len(sc.Enum) > 0 && !slices.Contains(sc.Enum, v) {
+		return []error{fmt.Errorf("%s: %v is not one of %v", path, v, sc.Enum)}
+	}
+
+	var errs []error
+	switch v := v.(type) {
+	case map[string]any:
+		if sc.Properties == nil {
+			return nil
+		}
+		for _, name := range sc.Required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s: missing required property %q", path, name))
+			}
+		}
+		for name, value := range v {
+			prop, ok := sc.Properties[name]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: property %q is not in spec", path, name))
+				continue
+			}
+			errs = append(errs, s.validate(prop, value, path+"."+name)...)
+		}
+	case []any:
+		if sc.Items != nil {
+			for i, item := range v {
+				errs = append(errs, s.validate(sc.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+func TestRoutesAreDocumented(t *testing.T) {
+	s := loadSpec(t)
+	e := echo.New()
+	handlers.RegisterRoutes(e, new(mockService))
+	handlers.RegisterAdminRoutes(e, new(mockService), "secret")
+
+	for _, route := range e.Routes() {
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		_, ok := s.Paths[path][strings.ToLower(route.Method)]
+		assert.True(t, ok, "%s %s is not described in spec", route.Method, path)
+	}
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	slogpretty.NewLogger()
+	s := loadSpec(t)
+
+	interval := 3600
+	archive := model.StatusArchive
+	linkID := 7
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	link := &model.Link{
+		ID:            7,
+		Link:          "https://example.com/news",
+		Original:      "Example.com/news/?utm_source=tg",
+		Tags:          []string{"work"},
+		Kind:          "html",
+		Selector:      "#news",
+		Status:        model.StatusActive,
+		CheckInterval: &interval,
+		Filters:       []string{"-user:bot"},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		path   string
+		body   string
+		// запрос без токена администратора
+		noAuth     bool
+		mockSetup  func(m *mockService)
+		wantStatus int
+	}{
+		{
+			name: "register chat", method: http.MethodPost, target: "/tg-chat/1", path: "/tg-chat/{id}",
+			mockSetup:  func(m *mockService) { m.On("AddTgChat", 1).Return(nil) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "register chat with bad id", method: http.MethodPost, target: "/tg-chat/abc", path: "/tg-chat/{id}",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "delete chat", method: http.MethodDelete, target: "/tg-chat/1", path: "/tg-chat/{id}",
+			mockSetup:  func(m *mockService) { m.On("DeleteTgChat", 1).Return(nil) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "delete missing chat", method: http.MethodDelete, target: "/tg-chat/2", path: "/tg-chat/{id}",
+			mockSetup:  func(m *mockService) { m.On("DeleteTgChat", 2).Return(errors.New("not found")) },
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "list links", method: http.MethodGet, target: "/links?tag=work", path: "/links",
+			mockSetup: func(m *mockService) {
+				m.On("GetLinks", 123, []string{"work"}).Return([]model.Link{*link, *model.NewLink(8, "https://github.com/foo/bar", nil, 1)}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "add link", method: http.MethodPost, target: "/links", path: "/links",
+			body: `{"link": "https://example.com/news", "tags": ["work"], "filters": ["-user:bot"], "selector": "#news"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddLink", 123, mock.Anything).Return(link, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "add link without url", method: http.MethodPost, target: "/links", path: "/links",
+			body:       `{"tags": ["work"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "delete link", method: http.MethodDelete, target: "/links", path: "/links",
+			body: `{"link": "https://example.com/news"}`,
+			mockSetup: func(m *mockService) {
+				m.On("DeleteLink", 123, model.LinkDeleteRequestDTO{Link: "https://example.com/news"}).Return(link, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "delete missing link", method: http.MethodDelete, target: "/links", path: "/links",
+			body: `{"link": "https://example.org"}`,
+			mockSetup: func(m *mockService) {
+				m.On("DeleteLink", 123, model.LinkDeleteRequestDTO{Link: "https://example.org"}).
+					Return((*model.Link)(nil), repository.ErrNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "pause link", method: http.MethodPatch, target: "/links", path: "/links",
+			body: `{"link": "https://example.com/news", "status": "archive"}`,
+			mockSetup: func(m *mockService) {
+				m.On("UpdateLink", 123, model.LinkPatchRequestDTO{Link: "https://example.com/news", Status: &archive}).
+					Return(&model.Link{ID: 7, Link: "https://example.com/news", Kind: "html", Status: archive}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "link history", method: http.MethodGet, target: "/links/7/updates", path: "/links/{id}/updates",
+			mockSetup: func(m *mockService) {
+				m.On("GetLinkUpdates", 123, 7, 50, 0).Return([]model.HistoryEntry{{
+					ID: 1, LinkID: 7, Type: "change", URL: link.Link, Preview: "+ news",
+					Payload: []byte(`{"type":"change"}`), CreatedAt: now, DetectedAt: now,
+				}}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "history of unknown link", method: http.MethodGet, target: "/links/9/updates", path: "/links/{id}/updates",
+			mockSetup: func(m *mockService) {
+				m.On("GetLinkUpdates", 123, 9, 50, 0).Return([]model.HistoryEntry(nil), repository.ErrNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "add token", method: http.MethodPost, target: "/tokens", path: "/tokens",
+			body: `{"name": "github", "token": "ghp_secret"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddToken", 123, model.TokenRequestDTO{Name: "github", Token: "ghp_secret"}).
+					Return(&model.Token{ID: 3, ChatID: 123, Name: "github", CreatedAt: now}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "list tokens", method: http.MethodGet, target: "/tokens", path: "/tokens",
+			mockSetup: func(m *mockService) {
+				m.On("GetTokens", 123).Return([]model.Token{{ID: 3, ChatID: 123, CreatedAt: now}}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "delete missing token", method: http.MethodDelete, target: "/tokens/4", path: "/tokens/{id}",
+			mockSetup: func(m *mockService) {
+				m.On("DeleteToken", 123, 4).Return((*model.Token)(nil), repository.ErrNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "dead letters", method: http.MethodGet, target: "/admin/dead-letters", path: "/admin/dead-letters",
+			mockSetup: func(m *mockService) {
+				m.On("GetDeadLetters", 50, 0).Return([]model.DeadLetter{
+					{ID: 1, LinkID: &linkID, Payload: []byte(`{"id":7}`), Attempts: 10, Error: "bot is down", CreatedAt: now, FailedAt: now},
+					{ID: 2, Payload: []byte(`{"id":8}`), Attempts: 10, CreatedAt: now, FailedAt: now},
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "replay dead letter", method: http.MethodPost, target: "/admin/dead-letters/1/replay", path: "/admin/dead-letters/{id}/replay",
+			mockSetup:  func(m *mockService) { m.On("ReplayDeadLetter", int64(1)).Return(nil) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "dead letters without admin token", method: http.MethodGet, target: "/admin/dead-letters", path: "/admin/dead-letters",
+			noAuth:     true,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockService)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockSvc)
+			}
+			e := echo.New()
+			handlers.RegisterMiddlewares(e, 0)
+			handlers.RegisterRoutes(e, mockSvc)
+			handlers.RegisterAdminRoutes(e, mockSvc, "secret")
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("Tg-Chat-Id", "123")
+			if !tt.noAuth {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			mockSvc.AssertExpectations(t)
+
+			op, ok := s.Paths[tt.path][strings.ToLower(tt.method)]
+			require.True(t, ok, "%s %s is not described in spec", tt.method, tt.path)
+			resp, ok := op.Responses[strconv.Itoa(rec.Code)]
+			require.True(t, ok, "status %d is not described in spec", rec.Code)
+
+			content, ok := resp.Content["application/json"]
+			if !ok {
+				assert.Empty(t, rec.Body.String(), "spec describes no body")
+				return
+			}
+			var body any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Empty(t, s.validate(content.Schema, body, "body"))
+		})
+	}
+}
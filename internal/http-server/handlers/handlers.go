@@ -1,13 +1,23 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/grigory222/scraptor/internal/clients/ratelimit"
+	"github.com/grigory222/scraptor/internal/clients/webpage"
+	"github.com/grigory222/scraptor/internal/filters"
 	"github.com/grigory222/scraptor/internal/http-server/middlewares"
 	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/grigory222/scraptor/internal/secrets"
 	"github.com/grigory222/scraptor/internal/service"
+	"github.com/grigory222/scraptor/internal/sources"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -29,14 +39,46 @@ func RegisterRoutes(e *echo.Echo, svc service.IService) {
 	e.POST("/links", h.AddLink)
 	e.GET("/links", h.GetLinks)
 	e.DELETE("/links", h.DeleteLink)
+	e.PATCH("/links", h.UpdateLink)
+	e.GET("/links/:id/updates", h.GetLinkUpdates)
+	e.POST("/tokens", h.AddToken)
+	e.GET("/tokens", h.GetTokens)
+	e.DELETE("/tokens/:id", h.DeleteToken)
 }
 
-func RegisterMiddlewares(e *echo.Echo) {
+// RegisterAdminRoutes регистрирует служебные маршруты /admin,
+// доступные только с токеном администратора
+func RegisterAdminRoutes(e *echo.Echo, svc service.IService, adminToken string) {
+	h := NewHandler(svc)
+	auth := middlewares.AdminAuth(adminToken)
+	admin := e.Group("/admin")
+	admin.GET("/dead-letters", h.GetDeadLetters, auth)
+	admin.POST("/dead-letters/:id/replay", h.ReplayDeadLetter, auth)
+}
+
+// RateLimitStater отдаёт состояние ограничителей запросов к источникам
+type RateLimitStater interface {
+	State() []ratelimit.HostState
+}
+
+// RegisterDebugRoutes регистрирует отладочные маршруты, закрытые тем же токеном, что и /admin
+func RegisterDebugRoutes(e *echo.Echo, limits RateLimitStater, adminToken string) {
+	e.GET("/debug/ratelimits", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, limits.State())
+	}, middlewares.AdminAuth(adminToken))
+}
+
+// RegisterMiddlewares регистрирует middleware. requestTimeout ограничивает время
+// обработки запроса вместе с запросами к БД; 0 - без ограничения
+func RegisterMiddlewares(e *echo.Echo, requestTimeout time.Duration) {
 	e.Use(middleware.RequestID())
 	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
 		Format: "method=${method}, uri=${uri}, status=${status}\n",
 	}))
 	e.Use(middlewares.ErrorHandlerMiddleware)
+	if requestTimeout > 0 {
+		e.Use(middleware.ContextTimeout(requestTimeout))
+	}
 }
 
 func (h *Handler) AddTgChat(c echo.Context) error {
@@ -44,23 +86,72 @@ func (h *Handler) AddTgChat(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	err = h.service.AddTgChat(id)
+	err = h.service.AddTgChat(c.Request().Context(), id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Couldn't add tg-chat with such id: %d\nError: %s", id, err.Error()))
+		return serviceError(c, err, http.StatusBadRequest, fmt.Sprintf("Couldn't add tg-chat with such id: %d\nError: %s", id, err.Error()))
 	}
-	return c.JSON(http.StatusCreated, "")
+	return c.NoContent(http.StatusOK)
 }
 
 func (h *Handler) DeleteTgChat(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return err
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
-	err = h.service.DeleteTgChat(id)
+	err = h.service.DeleteTgChat(c.Request().Context(), id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Couldn't delete tg-chat with such id: %d\nError: %s", id, err.Error()))
+		return serviceError(c, err, http.StatusNotFound, fmt.Sprintf("Couldn't delete tg-chat with such id: %d\nError: %s", id, err.Error()))
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// StatusClientClosedRequest - клиент закрыл соединение, не дождавшись ответа
+const StatusClientClosedRequest = 499
+
+// contextError возвращает ответ для ошибки, вызванной истечением времени запроса
+// или отключением клиента, и nil для остальных ошибок. Драйвер БД не всегда
+// возвращает ошибку контекста, поэтому проверяется и сам контекст запроса
+func contextError(c echo.Context, err error) *echo.HTTPError {
+	if err == nil {
+		return nil
+	}
+	ctxErr := c.Request().Context().Err()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctxErr, context.DeadlineExceeded):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Request timed out")
+	case errors.Is(err, context.Canceled) || errors.Is(ctxErr, context.Canceled):
+		return echo.NewHTTPError(StatusClientClosedRequest, "Request canceled")
+	}
+	return nil
+}
+
+// serviceError превращает ошибку сервиса в ответ с кодом code,
+// если она не вызвана истечением времени запроса или отключением клиента
+func serviceError(c echo.Context, err error, code int, message string) *echo.HTTPError {
+	if httpErr := contextError(c, err); httpErr != nil {
+		return httpErr
 	}
-	return c.JSON(http.StatusOK, "")
+	return echo.NewHTTPError(code, message)
+}
+
+// invalidRequestErrors - ошибки сервиса, вызванные содержимым запроса. Их текст
+// безопасно показывать клиенту, остальные ошибки (БД, источники) отдаются как 500
+var invalidRequestErrors = []error{
+	service.ErrInvalidTag,
+	service.ErrInvalidToken,
+	service.ErrTokenRequired,
+	filters.ErrInvalidFilter,
+	webpage.ErrInvalidSelector,
+	sources.ErrAmbiguousLink,
+}
+
+func isInvalidRequest(err error) bool {
+	for _, target := range invalidRequestErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
 // ============= Links =============
@@ -93,15 +184,25 @@ func (h *Handler) AddLink(c echo.Context) error {
 		return httpErr
 	}
 
-	linkDAO, err := h.service.AddLink(chatID, linkReq)
-	if err != nil {
+	linkDAO, err := h.service.AddLink(c.Request().Context(), chatID, linkReq)
+	// проверка источника могла не уложиться во время запроса - ссылка тут ни при чём
+	if httpErr := contextError(c, err); httpErr != nil {
+		return httpErr
+	}
+	if errors.Is(err, sources.ErrUnsupportedLink) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Link is not supported: %s", linkReq.Link))
+	}
+	if isInvalidRequest(err) {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't add link")
+	}
 	if linkDAO == nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "such link already exists")
 	}
 	linkResp := linkDAO.ToResponseDTO()
-	return c.JSON(http.StatusCreated, linkResp)
+	return c.JSON(http.StatusOK, linkResp)
 }
 
 func (h *Handler) DeleteLink(c echo.Context) error {
@@ -110,35 +211,242 @@ func (h *Handler) DeleteLink(c echo.Context) error {
 		return err
 	}
 
+	if linkReq.Link == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "link field is required")
+	}
+
 	chatID, httpErr := ValidateTgChatHeader(c)
 	if httpErr != nil {
 		return httpErr
 	}
 
-	linkDAO, err := h.service.DeleteLink(chatID, linkReq)
+	linkDAO, err := h.service.DeleteLink(c.Request().Context(), chatID, linkReq)
+	if errors.Is(err, repository.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("No such link: %s", linkReq.Link))
+	}
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't delete link")
 	}
 	linkResp := linkDAO.ToResponseDTO()
 	return c.JSON(http.StatusOK, linkResp)
 }
 
-func (h *Handler) GetLinks(c echo.Context) error {
+// UpdateLink ставит отслеживание ссылки на паузу или возобновляет его
+// и меняет интервал проверки
+func (h *Handler) UpdateLink(c echo.Context) error {
+	var linkReq model.LinkPatchRequestDTO
+	if err := c.Bind(&linkReq); err != nil {
+		return err
+	}
+
+	if linkReq.Link == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "link field is required")
+	}
+	if linkReq.Status != nil && *linkReq.Status != model.StatusActive && *linkReq.Status != model.StatusArchive {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("status must be %q or %q", model.StatusActive, model.StatusArchive))
+	}
+	if linkReq.CheckInterval != nil && *linkReq.CheckInterval < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "check_interval must be non-negative")
+	}
+
 	chatID, httpErr := ValidateTgChatHeader(c)
 	if httpErr != nil {
 		return httpErr
 	}
 
-	linksDAO, err := h.service.GetLinks(chatID)
+	linkDAO, err := h.service.UpdateLink(c.Request().Context(), chatID, linkReq)
+	if errors.Is(err, repository.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("No such link: %s", linkReq.Link))
+	}
 	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't update link")
+	}
+	return c.JSON(http.StatusOK, linkDAO.ToResponseDTO())
+}
+
+// GetLinks возвращает ссылки чата. Параметр tag (можно повторять или
+// перечислять через запятую) оставляет ссылки, помеченные хотя бы одним из тегов
+func (h *Handler) GetLinks(c echo.Context) error {
+	chatID, httpErr := ValidateTgChatHeader(c)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var tags []string
+	for _, param := range c.QueryParams()["tag"] {
+		tags = append(tags, strings.Split(param, ",")...)
+	}
+
+	linksDAO, err := h.service.GetLinks(c.Request().Context(), chatID, tags)
+	if isInvalidRequest(err) {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't load links")
+	}
 
 	// convert to response DTO
-	linksResponse := make([]model.LinkResponseDTO, len(linksDAO))
+	linksResponse := model.ListLinksResponseDTO{
+		Links: make([]model.LinkResponseDTO, len(linksDAO)),
+		Size:  len(linksDAO),
+	}
 	for i, link := range linksDAO {
-		linksResponse[i] = *link.ToResponseDTO()
+		linksResponse.Links[i] = *link.ToResponseDTO()
 	}
 
 	return c.JSON(http.StatusOK, linksResponse)
 }
+
+// GetLinkUpdates возвращает историю событий ссылки, начиная с последних
+func (h *Handler) GetLinkUpdates(c echo.Context) error {
+	chatID, httpErr := ValidateTgChatHeader(c)
+	if httpErr != nil {
+		return httpErr
+	}
+	linkID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "incorrect link id")
+	}
+	limit, offset, httpErr := bindPage(c)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	entries, err := h.service.GetLinkUpdates(c.Request().Context(), chatID, linkID, limit, offset)
+	if errors.Is(err, repository.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("No link with id %d", linkID))
+	}
+	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't load link updates")
+	}
+
+	resp := make([]*model.HistoryEntryResponseDTO, len(entries))
+	for i := range entries {
+		resp[i] = entries[i].ToResponseDTO()
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// bindPage читает параметры пагинации limit и offset
+func bindPage(c echo.Context) (limit, offset int, httpErr *echo.HTTPError) {
+	limit = defaultPageSize
+	err := echo.QueryParamsBinder(c).
+		Int("limit", &limit).
+		Int("offset", &offset).
+		BindError()
+	if err != nil || limit < 1 || limit > maxPageSize || offset < 0 {
+		return 0, 0, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("limit must be between 1 and %d, offset must be non-negative", maxPageSize))
+	}
+	return limit, offset, nil
+}
+
+// ============= Tokens =============
+
+// AddToken сохраняет токен доступа чата. В ответе секрета нет
+func (h *Handler) AddToken(c echo.Context) error {
+	var tokenReq model.TokenRequestDTO
+	if err := c.Bind(&tokenReq); err != nil {
+		return err
+	}
+
+	chatID, httpErr := ValidateTgChatHeader(c)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	token, err := h.service.AddToken(c.Request().Context(), chatID, tokenReq)
+	if errors.Is(err, service.ErrInvalidToken) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if errors.Is(err, secrets.ErrNoKey) {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Token storage is not configured")
+	}
+	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't save token")
+	}
+	return c.JSON(http.StatusOK, token.ToResponseDTO())
+}
+
+func (h *Handler) GetTokens(c echo.Context) error {
+	chatID, httpErr := ValidateTgChatHeader(c)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	tokens, err := h.service.GetTokens(c.Request().Context(), chatID)
+	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't load tokens")
+	}
+
+	resp := make([]*model.TokenResponseDTO, len(tokens))
+	for i := range tokens {
+		resp[i] = tokens[i].ToResponseDTO()
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) DeleteToken(c echo.Context) error {
+	chatID, httpErr := ValidateTgChatHeader(c)
+	if httpErr != nil {
+		return httpErr
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "incorrect token id")
+	}
+
+	token, err := h.service.DeleteToken(c.Request().Context(), chatID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("No token with id %d", id))
+	}
+	if errors.Is(err, repository.ErrTokenInUse) {
+		return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("Token %d is used by links, delete them first", id))
+	}
+	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't delete token")
+	}
+	return c.JSON(http.StatusOK, token.ToResponseDTO())
+}
+
+// ============= Admin =============
+
+func (h *Handler) GetDeadLetters(c echo.Context) error {
+	limit, offset, httpErr := bindPage(c)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	letters, err := h.service.GetDeadLetters(c.Request().Context(), limit, offset)
+	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, "Couldn't load dead letters")
+	}
+
+	resp := make([]*model.DeadLetterResponseDTO, len(letters))
+	for i := range letters {
+		resp[i] = letters[i].ToResponseDTO()
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) ReplayDeadLetter(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "incorrect dead letter id")
+	}
+
+	err = h.service.ReplayDeadLetter(c.Request().Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("No dead letter with id %d", id))
+	}
+	if err != nil {
+		return serviceError(c, err, http.StatusInternalServerError, fmt.Sprintf("Couldn't replay dead letter %d", id))
+	}
+	return c.NoContent(http.StatusOK)
+}
@@ -1,14 +1,26 @@
 package handlers_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/grigory222/scraptor/internal/clients/ratelimit"
 	"github.com/grigory222/scraptor/internal/http-server/handlers"
+	"github.com/grigory222/scraptor/internal/http-server/middlewares"
+	slogpretty "github.com/grigory222/scraptor/internal/logger"
 	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/grigory222/scraptor/internal/secrets"
+	"github.com/grigory222/scraptor/internal/service"
+	"github.com/grigory222/scraptor/internal/sources"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,31 +31,66 @@ type mockService struct {
 }
 
 // добавим пустые реализации других методов интерфейса
-func (m *mockService) AddTgChat(id int) error {
+func (m *mockService) AddTgChat(_ context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *mockService) DeleteTgChat(id int) error {
+func (m *mockService) DeleteTgChat(_ context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *mockService) AddLink(userID int, req model.LinkRequestDTO) (*model.Link, error) {
+func (m *mockService) AddLink(_ context.Context, userID int, req model.LinkRequestDTO) (*model.Link, error) {
 	args := m.Called(userID, req)
 	return args.Get(0).(*model.Link), args.Error(1)
 }
 
-func (m *mockService) DeleteLink(userID int, req model.LinkDeleteRequestDTO) (*model.Link, error) {
+func (m *mockService) DeleteLink(_ context.Context, userID int, req model.LinkDeleteRequestDTO) (*model.Link, error) {
 	args := m.Called(userID, req)
 	return args.Get(0).(*model.Link), args.Error(1)
 }
 
-func (m *mockService) GetLinks(userID int) ([]model.Link, error) {
-	args := m.Called(userID)
+func (m *mockService) UpdateLink(_ context.Context, userID int, req model.LinkPatchRequestDTO) (*model.Link, error) {
+	args := m.Called(userID, req)
+	return args.Get(0).(*model.Link), args.Error(1)
+}
+
+func (m *mockService) GetLinks(_ context.Context, userID int, tags []string) ([]model.Link, error) {
+	args := m.Called(userID, tags)
 	return args.Get(0).([]model.Link), args.Error(1)
 }
 
+func (m *mockService) GetLinkUpdates(_ context.Context, chatID, linkID, limit, offset int) ([]model.HistoryEntry, error) {
+	args := m.Called(chatID, linkID, limit, offset)
+	return args.Get(0).([]model.HistoryEntry), args.Error(1)
+}
+
+func (m *mockService) GetDeadLetters(_ context.Context, limit, offset int) ([]model.DeadLetter, error) {
+	args := m.Called(limit, offset)
+	return args.Get(0).([]model.DeadLetter), args.Error(1)
+}
+
+func (m *mockService) ReplayDeadLetter(_ context.Context, id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockService) AddToken(_ context.Context, chatID int, req model.TokenRequestDTO) (*model.Token, error) {
+	args := m.Called(chatID, req)
+	return args.Get(0).(*model.Token), args.Error(1)
+}
+
+func (m *mockService) GetTokens(_ context.Context, chatID int) ([]model.Token, error) {
+	args := m.Called(chatID)
+	return args.Get(0).([]model.Token), args.Error(1)
+}
+
+func (m *mockService) DeleteToken(_ context.Context, chatID, id int) (*model.Token, error) {
+	args := m.Called(chatID, id)
+	return args.Get(0).(*model.Token), args.Error(1)
+}
+
 func TestAddTgChat(t *testing.T) {
 	e := echo.New()
 
@@ -60,7 +107,7 @@ func TestAddTgChat(t *testing.T) {
 			mockSetup: func(m *mockService) {
 				m.On("AddTgChat", 123).Return(nil)
 			},
-			expectedStatus: http.StatusCreated,
+			expectedStatus: http.StatusOK,
 			expectError:    false,
 		},
 		{
@@ -132,7 +179,7 @@ func TestDeleteTgChat(t *testing.T) {
 				m.On("DeleteTgChat", 123).Return(nil)
 			},
 			wantStatus: http.StatusOK,
-			wantBody:   "\"\"\n",
+			wantBody:   "",
 		},
 		{
 			name:  "invalid id (not a number)",
@@ -141,7 +188,7 @@ func TestDeleteTgChat(t *testing.T) {
 				// не нужен мок, ошибка произойдёт на уровне парсинга
 			},
 			wantStatus: http.StatusBadRequest,
-			wantBody:   "strconv.Atoi: parsing \"abc\": invalid syntax\n",
+			wantBody:   "strconv.Atoi: parsing \"abc\": invalid syntax",
 		},
 		{
 			name:  "id not found in service",
@@ -172,14 +219,9 @@ func TestDeleteTgChat(t *testing.T) {
 			err := h.DeleteTgChat(c)
 			if err != nil {
 				var httpErr *echo.HTTPError
-				ok := errors.As(err, &httpErr)
-				if ok {
-					assert.Equal(t, tt.wantStatus, httpErr.Code)
-					assert.Equal(t, tt.wantBody, httpErr.Message)
-				} else {
-					// ошибка парсинга id, возвращается напрямую
-					assert.Equal(t, tt.wantBody, err.Error()+"\n")
-				}
+				assert.True(t, errors.As(err, &httpErr))
+				assert.Equal(t, tt.wantStatus, httpErr.Code)
+				assert.Equal(t, tt.wantBody, httpErr.Message)
 			} else {
 				assert.Equal(t, tt.wantStatus, rec.Code)
 				assert.Equal(t, tt.wantBody, rec.Body.String())
@@ -247,21 +289,64 @@ func TestAddLink(t *testing.T) {
 		{
 			name:        "success with token",
 			headerValue: "123",
-			requestBody: `{"link": "https://example.com", "tag": "test", "token_id": 1}`,
+			requestBody: `{"link": "https://example.com", "tags": ["test"], "token_id": 1}`,
 			mockSetup: func(m *mockService) {
 				m.On("AddLink", 123, model.LinkRequestDTO{
 					Link:    "https://example.com",
-					Tag:     "test",
+					Tags:    []string{"test"},
 					TokenID: 1,
-				}).Return(model.NewLink(1, "https://example.com", "test", 1), nil)
+				}).Return(model.NewLink(1, "https://example.com", []string{"test"}, 1), nil)
+			},
+			wantStatus:   http.StatusOK,
+			wantResponse: `{"id":1,"url":"https://example.com","tags":["test"],"token_id":1,"kind":"","filters":[]}`,
+		},
+		{
+			name:        "success with selector",
+			headerValue: "123",
+			requestBody: `{"link": "https://example.com/news", "selector": "#news li"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddLink", 123, model.LinkRequestDTO{
+					Link:     "https://example.com/news",
+					Selector: "#news li",
+				}).Return(&model.Link{ID: 2, Link: "https://example.com/news", Kind: "html", Selector: "#news li"}, nil)
+			},
+			wantStatus:   http.StatusOK,
+			wantResponse: `{"id":2,"url":"https://example.com/news","tags":[],"token_id":0,"kind":"html","selector":"#news li","filters":[]}`,
+		},
+		{
+			name:        "unsupported link",
+			headerValue: "123",
+			requestBody: `{"link": "https://unknown.org"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddLink", 123, model.LinkRequestDTO{Link: "https://unknown.org"}).
+					Return((*model.Link)(nil), fmt.Errorf("%w: https://unknown.org", sources.ErrUnsupportedLink))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "token required",
+			headerValue: "123",
+			requestBody: `{"link": "https://vk.com/im"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddLink", 123, model.LinkRequestDTO{Link: "https://vk.com/im"}).
+					Return((*model.Link)(nil), fmt.Errorf("%w: vk links require token_id", service.ErrTokenRequired))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "database error",
+			headerValue: "123",
+			requestBody: `{"link": "https://example.com"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddLink", 123, model.LinkRequestDTO{Link: "https://example.com"}).
+					Return((*model.Link)(nil), errors.New(`pq: relation "links" does not exist`))
 			},
-			wantStatus:   http.StatusCreated,
-			wantResponse: `{"id":1,"link":"https://example.com","tag":"test","token_id":1}`,
+			wantStatus: http.StatusInternalServerError,
 		},
 		{
 			name:        "invalid request - missing link",
 			headerValue: "123",
-			requestBody: `{"tag": "test"}`,
+			requestBody: `{"tags": ["test"]}`,
 			mockSetup:   func(m *mockService) {},
 			wantStatus:  http.StatusBadRequest,
 		},
@@ -287,7 +372,10 @@ func TestAddLink(t *testing.T) {
 			err := h.AddLink(c)
 
 			if tt.wantStatus >= 400 {
-				assert.Error(t, err)
+				var httpErr *echo.HTTPError
+				if assert.ErrorAs(t, err, &httpErr) {
+					assert.Equal(t, tt.wantStatus, httpErr.Code)
+				}
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.wantStatus, rec.Code)
@@ -317,10 +405,10 @@ func TestDeleteLink(t *testing.T) {
 			mockSetup: func(m *mockService) {
 				m.On("DeleteLink", 123, model.LinkDeleteRequestDTO{
 					Link: "https://example.com",
-				}).Return(model.NewLink(1, "https://example.com", "test", 1), nil)
+				}).Return(model.NewLink(1, "https://example.com", []string{"test"}, 1), nil)
 			},
 			wantStatus:   http.StatusOK,
-			wantResponse: `{"id":1,"link":"https://example.com","tag":"test","token_id":1}`,
+			wantResponse: `{"id":1,"url":"https://example.com","tags":["test"],"token_id":1,"kind":"","filters":[]}`,
 		},
 	}
 
@@ -356,10 +444,85 @@ func TestDeleteLink(t *testing.T) {
 	}
 }
 
+func TestUpdateLink(t *testing.T) {
+	archive := model.StatusArchive
+	interval := 3600
+
+	tests := []struct {
+		name         string
+		requestBody  string
+		mockSetup    func(m *mockService)
+		wantStatus   int
+		wantResponse string
+	}{
+		{
+			name:        "pause with interval",
+			requestBody: `{"link": "https://example.com", "status": "archive", "check_interval": 3600}`,
+			mockSetup: func(m *mockService) {
+				m.On("UpdateLink", 123, model.LinkPatchRequestDTO{
+					Link: "https://example.com", Status: &archive, CheckInterval: &interval,
+				}).Return(&model.Link{ID: 1, Link: "https://example.com", Status: archive, CheckInterval: &interval}, nil)
+			},
+			wantStatus:   http.StatusOK,
+			wantResponse: `{"id":1,"url":"https://example.com","tags":[],"token_id":0,"kind":"","status":"archive","check_interval":3600,"filters":[]}`,
+		},
+		{
+			name:        "unknown status",
+			requestBody: `{"link": "https://example.com", "status": "deleted"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "negative interval",
+			requestBody: `{"link": "https://example.com", "check_interval": -5}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "link not tracked",
+			requestBody: `{"link": "https://example.org", "check_interval": 0}`,
+			mockSetup: func(m *mockService) {
+				m.On("UpdateLink", 123, mock.Anything).Return((*model.Link)(nil), repository.ErrNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPatch, "/links", strings.NewReader(tt.requestBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("Tg-Chat-Id", "123")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			mockSvc := new(mockService)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockSvc)
+			}
+			h := handlers.NewHandler(mockSvc)
+
+			err := h.UpdateLink(c)
+
+			if tt.wantStatus >= 400 {
+				var httpErr *echo.HTTPError
+				assert.ErrorAs(t, err, &httpErr)
+				assert.Equal(t, tt.wantStatus, httpErr.Code)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantStatus, rec.Code)
+				assert.JSONEq(t, tt.wantResponse, rec.Body.String())
+			}
+
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
 func TestGetLinks(t *testing.T) {
 	tests := []struct {
 		name         string
 		headerValue  string
+		query        string
 		mockSetup    func(m *mockService)
 		wantStatus   int
 		wantResponse string
@@ -368,32 +531,44 @@ func TestGetLinks(t *testing.T) {
 			name:        "success with links",
 			headerValue: "123",
 			mockSetup: func(m *mockService) {
-				m.On("GetLinks", 123).Return([]model.Link{
-					*model.NewLink(1, "https://example.com", "test1", 1),
-					*model.NewLink(2, "https://example.org", "test2", 0),
+				m.On("GetLinks", 123, []string(nil)).Return([]model.Link{
+					*model.NewLink(1, "https://example.com", []string{"test1"}, 1),
+					*model.NewLink(2, "https://example.org", []string{"test2"}, 0),
 				}, nil)
 			},
 			wantStatus: http.StatusOK,
-			wantResponse: `[
-                {"id":1,"link":"https://example.com","tag":"test1","token_id":1},
-                {"id":2,"link":"https://example.org","tag":"test2","token_id":0}
-            ]`,
+			wantResponse: `{"links": [
+                {"id":1,"url":"https://example.com","tags":["test1"],"token_id":1,"kind":"","filters":[]},
+                {"id":2,"url":"https://example.org","tags":["test2"],"token_id":0,"kind":"","filters":[]}
+            ], "size": 2}`,
 		},
 		{
 			name:        "empty list",
 			headerValue: "123",
 			mockSetup: func(m *mockService) {
-				m.On("GetLinks", 123).Return([]model.Link{}, nil)
+				m.On("GetLinks", 123, []string(nil)).Return([]model.Link{}, nil)
 			},
 			wantStatus:   http.StatusOK,
-			wantResponse: `[]`,
+			wantResponse: `{"links": [], "size": 0}`,
+		},
+		{
+			name:        "filter by tags",
+			headerValue: "123",
+			query:       "?tag=work&tag=home,news",
+			mockSetup: func(m *mockService) {
+				m.On("GetLinks", 123, []string{"work", "home", "news"}).Return([]model.Link{
+					*model.NewLink(1, "https://example.com", []string{"news", "work"}, 1),
+				}, nil)
+			},
+			wantStatus:   http.StatusOK,
+			wantResponse: `{"links": [{"id":1,"url":"https://example.com","tags":["news","work"],"token_id":1,"kind":"","filters":[]}], "size": 1}`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			e := echo.New()
-			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req := httptest.NewRequest(http.MethodGet, "/links"+tt.query, nil)
 			if tt.headerValue != "" {
 				req.Header.Set("Tg-Chat-Id", tt.headerValue)
 			}
@@ -420,3 +595,543 @@ func TestGetLinks(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLinkUpdates(t *testing.T) {
+	createdAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		path         string
+		mockSetup    func(m *mockService)
+		wantStatus   int
+		wantResponse string
+	}{
+		{
+			name: "history page",
+			path: "/links/7/updates?limit=1&offset=2",
+			mockSetup: func(m *mockService) {
+				m.On("GetLinkUpdates", 123, 7, 1, 2).Return([]model.HistoryEntry{{
+					ID:         5,
+					LinkID:     7,
+					Type:       "issue",
+					Title:      "Bug",
+					Author:     "alice",
+					URL:        "https://github.com/foo/bar/issues/1",
+					Payload:    []byte(`{"type":"issue","title":"Bug"}`),
+					CreatedAt:  createdAt,
+					DetectedAt: createdAt.Add(time.Minute),
+				}}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: `[{"id":5,"type":"issue","title":"Bug","author":"alice","url":"https://github.com/foo/bar/issues/1",
+				"payload":{"type":"issue","title":"Bug"},"created_at":"2025-05-01T12:00:00Z","detected_at":"2025-05-01T12:01:00Z"}]`,
+		},
+		{
+			name: "link of another chat",
+			path: "/links/8/updates",
+			mockSetup: func(m *mockService) {
+				m.On("GetLinkUpdates", 123, 8, 50, 0).Return([]model.HistoryEntry(nil), repository.ErrNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "bad link id",
+			path:       "/links/abc/updates",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad page",
+			path:       "/links/7/updates?offset=-1",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			mockSvc := new(mockService)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockSvc)
+			}
+			handlers.RegisterRoutes(e, mockSvc)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Tg-Chat-Id", "123")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantResponse != "" {
+				assert.JSONEq(t, tt.wantResponse, rec.Body.String())
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetDeadLetters(t *testing.T) {
+	linkID := 3
+	failedAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		query        string
+		mockSetup    func(m *mockService)
+		wantStatus   int
+		wantResponse string
+	}{
+		{
+			name:  "default page",
+			query: "",
+			mockSetup: func(m *mockService) {
+				m.On("GetDeadLetters", 50, 0).Return([]model.DeadLetter{{
+					ID:        1,
+					LinkID:    &linkID,
+					Payload:   []byte(`{"id":3,"url":"https://github.com/foo/bar"}`),
+					Attempts:  10,
+					Error:     "bot is down",
+					CreatedAt: failedAt.Add(-time.Hour),
+					FailedAt:  failedAt,
+				}}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: `[{"id":1,"link_id":3,"payload":{"id":3,"url":"https://github.com/foo/bar"},"attempts":10,
+				"error":"bot is down","created_at":"2025-05-01T11:00:00Z","failed_at":"2025-05-01T12:00:00Z"}]`,
+		},
+		{
+			name:  "custom page",
+			query: "?limit=10&offset=20",
+			mockSetup: func(m *mockService) {
+				m.On("GetDeadLetters", 10, 20).Return([]model.DeadLetter{}, nil)
+			},
+			wantStatus:   http.StatusOK,
+			wantResponse: `[]`,
+		},
+		{
+			name:       "invalid limit",
+			query:      "?limit=1000",
+			mockSetup:  func(m *mockService) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "limit is not a number",
+			query:      "?limit=abc",
+			mockSetup:  func(m *mockService) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "service error",
+			query: "",
+			mockSetup: func(m *mockService) {
+				m.On("GetDeadLetters", 50, 0).Return([]model.DeadLetter{}, errors.New("db is down"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/admin/dead-letters"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			mockSvc := new(mockService)
+			tt.mockSetup(mockSvc)
+			h := handlers.NewHandler(mockSvc)
+
+			err := h.GetDeadLetters(c)
+
+			if tt.wantStatus >= 400 {
+				var httpErr *echo.HTTPError
+				if assert.ErrorAs(t, err, &httpErr) {
+					assert.Equal(t, tt.wantStatus, httpErr.Code)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantStatus, rec.Code)
+				assert.JSONEq(t, tt.wantResponse, rec.Body.String())
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	slogpretty.NewLogger()
+
+	tests := []struct {
+		name       string
+		id         string
+		mockSetup  func(m *mockService)
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name: "success",
+			id:   "5",
+			mockSetup: func(m *mockService) {
+				m.On("ReplayDeadLetter", int64(5)).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "not found",
+			id:   "6",
+			mockSetup: func(m *mockService) {
+				m.On("ReplayDeadLetter", int64(6)).Return(repository.ErrNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+			wantError:  "No dead letter with id 6",
+		},
+		{
+			name:       "invalid id",
+			id:         "abc",
+			mockSetup:  func(m *mockService) {},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "incorrect dead letter id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockService)
+			tt.mockSetup(mockSvc)
+
+			// через echo целиком, чтобы проверить формат ошибки
+			e := echo.New()
+			handlers.RegisterMiddlewares(e, 0)
+			handlers.RegisterAdminRoutes(e, mockSvc, "secret")
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/dead-letters/"+tt.id+"/replay", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantError != "" {
+				var apiErr middlewares.APIError
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+				assert.Equal(t, tt.wantError, apiErr.Description)
+				assert.Equal(t, strconv.Itoa(tt.wantStatus), apiErr.Code)
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	slogpretty.NewLogger()
+
+	tests := []struct {
+		name       string
+		adminToken string
+		header     string
+		wantStatus int
+	}{
+		{name: "valid token", adminToken: "secret", header: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "no header", adminToken: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", adminToken: "secret", header: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", adminToken: "secret", header: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "token is not configured", header: "Bearer ", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockService)
+			if tt.wantStatus == http.StatusOK {
+				mockSvc.On("GetDeadLetters", 50, 0).Return([]model.DeadLetter{}, nil)
+			}
+
+			e := echo.New()
+			handlers.RegisterMiddlewares(e, 0)
+			handlers.RegisterAdminRoutes(e, mockSvc, tt.adminToken)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/dead-letters", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTokens(t *testing.T) {
+	slogpretty.NewLogger()
+
+	created := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	token := &model.Token{ID: 3, ChatID: 123, Name: "github", CreatedAt: created}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		mockSetup  func(m *mockService)
+		wantStatus int
+		wantBody   string
+		wantError  string
+	}{
+		{
+			name:   "add",
+			method: http.MethodPost, target: "/tokens",
+			body: `{"name": "github", "token": "ghp_secret"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddToken", 123, model.TokenRequestDTO{Name: "github", Token: "ghp_secret"}).Return(token, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":3,"name":"github","created_at":"2025-05-01T12:00:00Z"}`,
+		},
+		{
+			name:   "add empty token",
+			method: http.MethodPost, target: "/tokens",
+			body: `{"name": "github"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddToken", 123, model.TokenRequestDTO{Name: "github"}).
+					Return((*model.Token)(nil), fmt.Errorf("%w: token is empty", service.ErrInvalidToken))
+			},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid token: token is empty",
+		},
+		{
+			name:   "add without key",
+			method: http.MethodPost, target: "/tokens",
+			body: `{"token": "ghp_secret"}`,
+			mockSetup: func(m *mockService) {
+				m.On("AddToken", 123, model.TokenRequestDTO{Token: "ghp_secret"}).Return((*model.Token)(nil), secrets.ErrNoKey)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantError:  "Token storage is not configured",
+		},
+		{
+			name:   "list",
+			method: http.MethodGet, target: "/tokens",
+			mockSetup: func(m *mockService) {
+				m.On("GetTokens", 123).Return([]model.Token{*token}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"id":3,"name":"github","created_at":"2025-05-01T12:00:00Z"}]`,
+		},
+		{
+			name:   "empty list",
+			method: http.MethodGet, target: "/tokens",
+			mockSetup: func(m *mockService) {
+				m.On("GetTokens", 123).Return([]model.Token(nil), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `[]`,
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete, target: "/tokens/3",
+			mockSetup: func(m *mockService) {
+				m.On("DeleteToken", 123, 3).Return(token, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":3,"name":"github","created_at":"2025-05-01T12:00:00Z"}`,
+		},
+		{
+			name:   "delete token of another chat",
+			method: http.MethodDelete, target: "/tokens/4",
+			mockSetup: func(m *mockService) {
+				m.On("DeleteToken", 123, 4).Return((*model.Token)(nil), repository.ErrNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+			wantError:  "No token with id 4",
+		},
+		{
+			name:   "delete token used by links",
+			method: http.MethodDelete, target: "/tokens/3",
+			mockSetup: func(m *mockService) {
+				m.On("DeleteToken", 123, 3).Return((*model.Token)(nil), repository.ErrTokenInUse)
+			},
+			wantStatus: http.StatusConflict,
+			wantError:  "Token 3 is used by links, delete them first",
+		},
+		{
+			name:   "delete with invalid id",
+			method: http.MethodDelete, target: "/tokens/abc",
+			mockSetup:  func(m *mockService) {},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "incorrect token id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(mockService)
+			tt.mockSetup(mockSvc)
+
+			e := echo.New()
+			handlers.RegisterMiddlewares(e, 0)
+			handlers.RegisterRoutes(e, mockSvc)
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("Tg-Chat-Id", "123")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			}
+			if tt.wantError != "" {
+				var apiErr middlewares.APIError
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+				assert.Equal(t, tt.wantError, apiErr.Description)
+			}
+			assert.NotContains(t, rec.Body.String(), "ghp_secret")
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	slogpretty.NewLogger()
+
+	e := echo.New()
+	handlers.RegisterMiddlewares(e, 20*time.Millisecond)
+	e.GET("/slow", func(c echo.Context) error {
+		// так ведёт себя запрос к БД, отменённый по контексту
+		<-c.Request().Context().Done()
+		return c.Request().Context().Err()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var apiErr middlewares.APIError
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+	assert.Equal(t, strconv.Itoa(http.StatusServiceUnavailable), apiErr.Code)
+}
+
+// blockingService ждёт отмены контекста, как запрос к медленной БД или источнику
+type blockingService struct {
+	*mockService
+}
+
+func (blockingService) AddLink(ctx context.Context, _ int, _ model.LinkRequestDTO) (*model.Link, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("probe source: %w", ctx.Err())
+}
+
+func (blockingService) GetLinks(ctx context.Context, _ int, _ []string) ([]model.Link, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingService) AddTgChat(ctx context.Context, _ int) error {
+	<-ctx.Done()
+	// драйвер БД может вернуть свою ошибку вместо ошибки контекста
+	return errors.New("pq: canceling statement due to user request")
+}
+
+func TestServiceContextErrors(t *testing.T) {
+	slogpretty.NewLogger()
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		canceled bool
+		wantCode int
+	}{
+		{name: "get links timeout", method: http.MethodGet, target: "/links", wantCode: http.StatusServiceUnavailable},
+		{
+			name:     "add link timeout",
+			method:   http.MethodPost,
+			target:   "/links",
+			body:     `{"link": "https://example.com/feed"}`,
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{name: "driver error on timeout", method: http.MethodPost, target: "/tg-chat/1", wantCode: http.StatusServiceUnavailable},
+		{
+			name:     "client gone",
+			method:   http.MethodGet,
+			target:   "/links",
+			canceled: true,
+			wantCode: handlers.StatusClientClosedRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			handlers.RegisterMiddlewares(e, 20*time.Millisecond)
+			handlers.RegisterRoutes(e, blockingService{&mockService{}})
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("Tg-Chat-Id", "1")
+			if tt.canceled {
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				req = req.WithContext(ctx)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			var apiErr middlewares.APIError
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+			assert.Equal(t, strconv.Itoa(tt.wantCode), apiErr.Code)
+		})
+	}
+}
+
+func TestInternalErrorIsHidden(t *testing.T) {
+	slogpretty.NewLogger()
+
+	e := echo.New()
+	handlers.RegisterMiddlewares(e, 0)
+	e.GET("/broken", func(c echo.Context) error {
+		return errors.New(`pq: relation "links" does not exist`)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/broken", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "pq")
+	assert.NotContains(t, rec.Body.String(), "errors.errorString")
+}
+
+type fakeLimits []ratelimit.HostState
+
+func (f fakeLimits) State() []ratelimit.HostState {
+	return f
+}
+
+func TestRateLimitsDebug(t *testing.T) {
+	slogpretty.NewLogger()
+	blocked := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := echo.New()
+	handlers.RegisterMiddlewares(e, 0)
+	handlers.RegisterDebugRoutes(e, fakeLimits{
+		{Host: "api.github.com", RPS: 1, Burst: 5, Tokens: 4.5},
+		{Host: "www.reddit.com", RPS: 0.5, Burst: 1, BlockedUntil: &blocked},
+	}, "secret")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/ratelimits", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/ratelimits", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"host":"api.github.com","rps":1,"burst":5,"tokens":4.5},
+		{"host":"www.reddit.com","rps":0.5,"burst":1,"tokens":0,"blocked_until":"2025-05-01T12:00:00Z"}
+	]`, rec.Body.String())
+}
@@ -52,24 +52,25 @@ func ErrorHandlerMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		err := next(c)
 		if err != nil {
-			var apiError *APIError
-
-			code := http.StatusInternalServerError
-
 			var he *echo.HTTPError
-			if errors.As(err, &he) {
-				code = he.Code
-				apiError = NewAPIError(
-					he.Message.(string),
-					strconv.Itoa(he.Code),
-					"HTTPError",
-					he.Message.(string),
-				)
+			if !errors.As(err, &he) {
+				// текст ошибок БД и драйверов клиенту не показываем, только пишем в лог
+				logger.Logger.Error("Unhandled error", "type", fmt.Sprintf("%T", err), "err", err)
+				code := http.StatusInternalServerError
+				message := http.StatusText(code)
+				return c.JSON(code, NewAPIError(message, strconv.Itoa(code), "InternalError", message))
 			}
 
+			message := fmt.Sprint(he.Message)
+			apiError := NewAPIError(
+				message,
+				strconv.Itoa(he.Code),
+				"HTTPError",
+				message,
+			)
 			logger.Logger.Error(apiError.Description)
 
-			return c.JSON(code, apiError)
+			return c.JSON(he.Code, apiError)
 		}
 		return nil
 	}
@@ -0,0 +1,294 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// lockID - ключ advisory lock, чтобы несколько экземпляров не мигрировали одновременно
+const lockID = 7_340_215
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration - одна версия схемы
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status - миграция и время её применения, nil - ещё не применена
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Load читает миграции из fsys, отсортированные по версии.
+// У каждой версии должен быть up-файл, down-файл необязателен
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: bad version in %s", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: version %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d has no up migration", mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator применяет и откатывает миграции, записывая применённые версии в schema_migrations
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+	log        *slog.Logger
+}
+
+// New загружает миграции из fsys
+func New(db *sqlx.DB, fsys fs.FS, log *slog.Logger) (*Migrator, error) {
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, log: log}, nil
+}
+
+// Up применяет все новые миграции по порядку и возвращает их число.
+// Каждая миграция применяется в своей транзакции вместе с записью о версии
+func (m *Migrator) Up() (int, error) {
+	conn, unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mig := range pending(m.migrations, applied) {
+		tx, err := conn.BeginTxx(context.Background(), nil)
+		if err != nil {
+			return count, err
+		}
+		if _, err := tx.Exec(mig.Up); err != nil {
+			tx.Rollback()
+			return count, fmt.Errorf("migrate: %d_%s up: %w", mig.Version, mig.Name, err)
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name)
+		if err != nil {
+			tx.Rollback()
+			return count, err
+		}
+		if err := tx.Commit(); err != nil {
+			return count, err
+		}
+		m.log.Info("Migration applied", "version", mig.Version, "name", mig.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Down откатывает последнюю применённую миграцию.
+// Возвращает false, если откатывать нечего
+func (m *Migrator) Down() (bool, error) {
+	conn, unlock, err := m.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(conn)
+	if err != nil {
+		return false, err
+	}
+	mig, ok := last(m.migrations, applied)
+	if !ok {
+		return false, nil
+	}
+	if mig.Down == "" {
+		return false, fmt.Errorf("migrate: %d_%s has no down migration", mig.Version, mig.Name)
+	}
+
+	tx, err := conn.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(mig.Down); err != nil {
+		return false, fmt.Errorf("migrate: %d_%s down: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	m.log.Info("Migration rolled back", "version", mig.Version, "name", mig.Name)
+	return true, nil
+}
+
+// Status возвращает все известные миграции и время их применения
+func (m *Migrator) Status() ([]Status, error) {
+	conn, unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// lock берёт соединение из пула и блокирует миграции на нём
+func (m *Migrator) lock() (*sqlx.Conn, func(), error) {
+	if m.db == nil {
+		return nil, nil, errors.New("migrate: no database connection")
+	}
+	conn, err := m.db.Connx(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			m.log.Warn("Can't release migration lock", "err", err)
+		}
+		conn.Close()
+	}
+	return conn, unlock, nil
+}
+
+// applied создаёт schema_migrations при необходимости и возвращает применённые версии.
+// База, созданная до появления миграций из init.sql, считается находящейся на версии 1:
+// первая миграция - это исходный init.sql, а следующие пропускают то, что в базе уже есть
+func (m *Migrator) applied(conn *sqlx.Conn) (map[int]time.Time, error) {
+	var exists bool
+	err := conn.GetContext(context.Background(), &exists, `SELECT to_regclass('schema_migrations') IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		var legacy bool
+		err := conn.GetContext(context.Background(), &legacy, `SELECT to_regclass('links') IS NOT NULL`)
+		if err != nil {
+			return nil, err
+		}
+		_, err = conn.ExecContext(context.Background(), `CREATE TABLE schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+		if err != nil {
+			return nil, err
+		}
+		if legacy && len(m.migrations) > 0 {
+			first := m.migrations[0]
+			_, err = conn.ExecContext(context.Background(), `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, first.Version, first.Name)
+			if err != nil {
+				return nil, err
+			}
+			m.log.Info("Existing schema marked as migrated", "version", first.Version, "name", first.Name)
+		}
+	}
+
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := conn.SelectContext(context.Background(), &rows, `SELECT version, applied_at FROM schema_migrations`); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	applied := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// pending возвращает неприменённые миграции по возрастанию версии
+func pending(migrations []Migration, applied map[int]time.Time) []Migration {
+	var result []Migration
+	for _, mig := range migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			result = append(result, mig)
+		}
+	}
+	return result
+}
+
+// last возвращает последнюю применённую миграцию
+func last(migrations []Migration, applied map[int]time.Time) (Migration, bool) {
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[migrations[i].Version]; ok {
+			return migrations[i], true
+		}
+	}
+	return Migration{}, false
+}
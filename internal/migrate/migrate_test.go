@@ -0,0 +1,187 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/testdb"
+	"github.com/grigory222/scraptor/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		fs      fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{
+			name: "sorted by version",
+			fs: fstest.MapFS{
+				"0002_tokens.up.sql":   {Data: []byte("CREATE TABLE t2")},
+				"0002_tokens.down.sql": {Data: []byte("DROP TABLE t2")},
+				"0001_init.up.sql":     {Data: []byte("CREATE TABLE t1")},
+				"0001_init.down.sql":   {Data: []byte("DROP TABLE t1")},
+				"migrations.go":        {Data: []byte("package migrations")},
+			},
+			want: []Migration{
+				{Version: 1, Name: "init", Up: "CREATE TABLE t1", Down: "DROP TABLE t1"},
+				{Version: 2, Name: "tokens", Up: "CREATE TABLE t2", Down: "DROP TABLE t2"},
+			},
+		},
+		{
+			name: "down is optional",
+			fs: fstest.MapFS{
+				"0001_init.up.sql": {Data: []byte("CREATE TABLE t1")},
+			},
+			want: []Migration{{Version: 1, Name: "init", Up: "CREATE TABLE t1"}},
+		},
+		{
+			name: "missing up",
+			fs: fstest.MapFS{
+				"0001_init.down.sql": {Data: []byte("DROP TABLE t1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "same version with different names",
+			fs: fstest.MapFS{
+				"0001_init.up.sql":  {Data: []byte("CREATE TABLE t1")},
+				"0001_other.up.sql": {Data: []byte("CREATE TABLE t2")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero version",
+			fs: fstest.MapFS{
+				"0000_init.up.sql": {Data: []byte("CREATE TABLE t1")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fs)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	got, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, got)
+
+	for i, mig := range got {
+		assert.Equal(t, i+1, mig.Version, "versions must have no gaps")
+		assert.NotEmpty(t, mig.Down, "%d_%s has no down migration", mig.Version, mig.Name)
+	}
+}
+
+func TestPendingAndLast(t *testing.T) {
+	all := []Migration{{Version: 1, Name: "init"}, {Version: 2, Name: "tokens"}, {Version: 3, Name: "tags"}}
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		applied     map[int]time.Time
+		wantPending []int
+		wantLast    int
+	}{
+		{name: "fresh database", applied: map[int]time.Time{}, wantPending: []int{1, 2, 3}},
+		{name: "partially applied", applied: map[int]time.Time{1: now}, wantPending: []int{2, 3}, wantLast: 1},
+		{name: "up to date", applied: map[int]time.Time{1: now, 2: now, 3: now}, wantLast: 3},
+		{name: "gap is filled", applied: map[int]time.Time{1: now, 3: now}, wantPending: []int{2}, wantLast: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var versions []int
+			for _, mig := range pending(all, tt.applied) {
+				versions = append(versions, mig.Version)
+			}
+			assert.Equal(t, tt.wantPending, versions)
+
+			mig, ok := last(all, tt.applied)
+			assert.Equal(t, tt.wantLast != 0, ok)
+			assert.Equal(t, tt.wantLast, mig.Version)
+		})
+	}
+}
+
+// TestUpgradeFromBaseline обновляет базу, созданную исходным init.sql, до последней версии и обратно
+func TestUpgradeFromBaseline(t *testing.T) {
+	db := testdb.New(t)
+	all, err := Load(migrations.FS)
+	require.NoError(t, err)
+
+	// база, созданная до появления миграций
+	_, err = db.Exec(all[0].Up)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO chats (id, type) VALUES (1, 'personal'), (2, 'personal');
+		INSERT INTO links (id, link, tag) VALUES
+			(1, 'https://github.com/foo/bar', 'work'),
+			(2, 'https://github.com/foo/bar', 'hobby'),
+			(3, 'https://example.com', NULL);
+		INSERT INTO chats_links (chat_id, link_id, status) VALUES (1, 1, 'active'), (2, 2, NULL), (1, 3, 'archive');
+	`)
+	require.NoError(t, err)
+
+	m, err := New(db, migrations.FS, nil)
+	require.NoError(t, err)
+	n, err := m.Up()
+	require.NoError(t, err)
+	assert.Equal(t, len(all)-1, n)
+
+	statuses, err := m.Status()
+	require.NoError(t, err)
+	for _, st := range statuses {
+		assert.NotNil(t, st.AppliedAt, "%d_%s is not applied", st.Version, st.Name)
+	}
+
+	var links []struct {
+		ChatID int    `db:"chat_id"`
+		LinkID int    `db:"link_id"`
+		Status string `db:"status"`
+		Tag    string `db:"tag"`
+	}
+	err = db.Select(&links, `
+		SELECT cl.chat_id, cl.link_id, cl.status, COALESCE(t.name, '') AS tag
+		FROM chats_links cl
+		LEFT JOIN links_tags lt ON lt.chat_id = cl.chat_id AND lt.link_id = cl.link_id
+		LEFT JOIN tags t ON t.id = lt.tag_id
+		ORDER BY cl.chat_id, cl.link_id`)
+	require.NoError(t, err)
+	require.Len(t, links, 3)
+	assert.Equal(t, 1, links[0].LinkID)
+	assert.Equal(t, "work", links[0].Tag)
+	assert.Equal(t, 3, links[1].LinkID)
+	assert.Equal(t, "archive", links[1].Status)
+	assert.Empty(t, links[1].Tag)
+	// дубликат слит в строку с наименьшим id вместе с тегом и статусом по умолчанию
+	assert.Equal(t, 2, links[2].ChatID)
+	assert.Equal(t, 1, links[2].LinkID)
+	assert.Equal(t, "active", links[2].Status)
+	assert.Equal(t, "hobby", links[2].Tag)
+
+	for {
+		ok, err := m.Down()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+	}
+	var exists bool
+	require.NoError(t, db.Get(&exists, `SELECT to_regclass('links') IS NOT NULL`))
+	assert.False(t, exists)
+}
@@ -1,10 +1,55 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
 type Link struct {
-	ID      int    `db:"id"`
-	Link    string `db:"link"`
-	Tag     string `db:"tag"`
-	TokenID *int   `db:"token_id"`
+	ID int `db:"id"`
+	// каноническая ссылка, общая для всех чатов
+	Link string `db:"link"`
+	// ссылка в том виде, в каком её добавил чат
+	Original string `db:"original_link"`
+	// теги, которыми чат пометил ссылку
+	Tags    pq.StringArray `db:"tags"`
+	TokenID *int           `db:"token_id"`
+	// тип источника, подобранный при добавлении ссылки
+	Kind string `db:"kind"`
+	// CSS-селектор отслеживаемой части страницы
+	Selector string `db:"selector"`
+	// статус отслеживания в чате: active или archive (на паузе)
+	Status string `db:"status"`
+	// интервал проверки в секундах, nil - интервал планировщика по умолчанию
+	CheckInterval *int `db:"check_interval"`
+	// фильтры событий чата, см. пакет filters
+	Filters pq.StringArray `db:"filters"`
+	// время последней успешной проверки, nil - ещё не проверялась
+	LastCheckedAt *time.Time `db:"last_checked_at"`
+	// произвольное состояние чекера (курсоры, хэши и т.п.)
+	State json.RawMessage `db:"state"`
+	// валидаторы последних ответов для условных запросов, ключ - URL запроса без query
+	Validators map[string]Validator `db:"-"`
+}
+
+// Validator - ETag и Last-Modified ответа источника
+type Validator struct {
+	ETag         string `db:"etag"`
+	LastModified string `db:"last_modified"`
+}
+
+// Статусы отслеживания ссылки в чате
+const (
+	StatusActive  = "active"
+	StatusArchive = "archive"
+)
+
+// LinkChat - чат, отслеживающий ссылку, и его фильтры событий
+type LinkChat struct {
+	ChatID  int            `db:"chat_id"`
+	Filters pq.StringArray `db:"filters"`
 }
 
 type Chat struct {
@@ -12,13 +57,123 @@ type Chat struct {
 	Type string `db:"type"`
 }
 
-func NewLink(id int, link, tag string, tokenID int) *Link {
-	return &Link{id, link, tag, &tokenID}
+// Update - новое событие, найденное чекером по ссылке
+type Update struct {
+	LinkID    int       `json:"link_id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Preview   string    `json:"preview,omitempty"`
+	Score     int       `json:"score,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HistoryEntry - сохранённое событие из истории ссылки
+type HistoryEntry struct {
+	ID      int64  `db:"id"`
+	LinkID  int    `db:"link_id"`
+	Type    string `db:"type"`
+	Title   string `db:"title"`
+	Author  string `db:"author"`
+	URL     string `db:"url"`
+	Preview string `db:"preview"`
+	// событие целиком, как его вернул чекер
+	Payload   json.RawMessage `db:"payload"`
+	CreatedAt time.Time       `db:"created_at"`
+	// когда событие нашёл планировщик
+	DetectedAt time.Time `db:"detected_at"`
+}
+
+// OutboxMessage - уведомление, ожидающее отправки
+type OutboxMessage struct {
+	ID       int64           `db:"id"`
+	LinkID   int             `db:"link_id"`
+	Payload  json.RawMessage `db:"payload"`
+	Attempts int             `db:"attempts"`
+}
+
+// DeadLetter - уведомление, которое так и не удалось отправить
+type DeadLetter struct {
+	ID       int64           `db:"id"`
+	LinkID   *int            `db:"link_id"`
+	Payload  json.RawMessage `db:"payload"`
+	Attempts int             `db:"attempts"`
+	Error    string          `db:"error"`
+	// когда уведомление попало в outbox
+	CreatedAt time.Time `db:"created_at"`
+	FailedAt  time.Time `db:"failed_at"`
+}
+
+// Token - токен доступа чата к источникам. Сам секрет в модель не попадает:
+// он хранится зашифрованным и расшифровывается только при запросе к источнику
+type Token struct {
+	ID        int       `db:"id"`
+	ChatID    int       `db:"chat_id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func NewLink(id int, link string, tags []string, tokenID int) *Link {
+	return &Link{ID: id, Link: link, Tags: tags, TokenID: &tokenID}
 }
 
 func (link *Link) ToResponseDTO() *LinkResponseDTO {
+	resp := &LinkResponseDTO{
+		ID:       link.ID,
+		URL:      link.Link,
+		Original: link.Original,
+		Tags:     link.Tags,
+		Kind:     link.Kind,
+		Selector: link.Selector,
+		Status:   link.Status,
+		Filters:  link.Filters,
+	}
 	if link.TokenID != nil {
-		return &LinkResponseDTO{link.ID, link.Link, link.Tag, *link.TokenID}
+		resp.TokenID = *link.TokenID
+	}
+	if link.CheckInterval != nil {
+		resp.CheckInterval = *link.CheckInterval
+	}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+	if resp.Filters == nil {
+		resp.Filters = []string{}
+	}
+	return resp
+}
+
+func (h *HistoryEntry) ToResponseDTO() *HistoryEntryResponseDTO {
+	return &HistoryEntryResponseDTO{
+		ID:         h.ID,
+		Type:       h.Type,
+		Title:      h.Title,
+		Author:     h.Author,
+		URL:        h.URL,
+		Preview:    h.Preview,
+		Payload:    h.Payload,
+		CreatedAt:  h.CreatedAt,
+		DetectedAt: h.DetectedAt,
+	}
+}
+
+func (d *DeadLetter) ToResponseDTO() *DeadLetterResponseDTO {
+	return &DeadLetterResponseDTO{
+		ID:        d.ID,
+		LinkID:    d.LinkID,
+		Payload:   d.Payload,
+		Attempts:  d.Attempts,
+		Error:     d.Error,
+		CreatedAt: d.CreatedAt,
+		FailedAt:  d.FailedAt,
+	}
+}
+
+func (t *Token) ToResponseDTO() *TokenResponseDTO {
+	return &TokenResponseDTO{
+		ID:        t.ID,
+		Name:      t.Name,
+		CreatedAt: t.CreatedAt,
 	}
-	return &LinkResponseDTO{link.ID, link.Link, link.Tag, 0}
 }
@@ -1,18 +1,88 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type LinkRequestDTO struct {
-	Link    string `json:"link"`
-	Tag     string `json:"tag"`
-	TokenID int    `json:"token_id"`
+	Link     string   `json:"link"`
+	Tags     []string `json:"tags"`
+	TokenID  int      `json:"token_id"`
+	Selector string   `json:"selector"`
+	// фильтры событий вида [-]kind:value
+	Filters []string `json:"filters"`
 }
 
 type LinkDeleteRequestDTO struct {
 	Link string `json:"link"`
 }
 
+// LinkPatchRequestDTO - изменение настроек отслеживания ссылки.
+// Незаданные поля не меняются, check_interval = 0 возвращает интервал по умолчанию
+type LinkPatchRequestDTO struct {
+	Link          string  `json:"link"`
+	Status        *string `json:"status"`
+	CheckInterval *int    `json:"check_interval"`
+}
+
 type LinkResponseDTO struct {
-	ID      int    `json:"id"`
-	Link    string `json:"link"`
-	Tag     string `json:"tag"`
-	TokenID int    `json:"token_id"`
+	ID            int      `json:"id"`
+	URL           string   `json:"url"`
+	Original      string   `json:"original_url,omitempty"`
+	Tags          []string `json:"tags"`
+	TokenID       int      `json:"token_id"`
+	Kind          string   `json:"kind"`
+	Selector      string   `json:"selector,omitempty"`
+	Status        string   `json:"status,omitempty"`
+	CheckInterval int      `json:"check_interval,omitempty"`
+	Filters       []string `json:"filters"`
+}
+
+type ListLinksResponseDTO struct {
+	Links []LinkResponseDTO `json:"links"`
+	Size  int               `json:"size"`
+}
+
+// TokenRequestDTO - новый токен доступа. Name помогает отличать токены в списке
+type TokenRequestDTO struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// TokenResponseDTO - описание токена без самого секрета
+type TokenResponseDTO struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LinkUpdate - уведомление для бота о событии по ссылке
+type LinkUpdate struct {
+	ID          int    `json:"id"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	TgChatIDs   []int  `json:"tgChatIds"`
+}
+
+type HistoryEntryResponseDTO struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	Title      string          `json:"title,omitempty"`
+	Author     string          `json:"author,omitempty"`
+	URL        string          `json:"url,omitempty"`
+	Preview    string          `json:"preview,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+	CreatedAt  time.Time       `json:"created_at"`
+	DetectedAt time.Time       `json:"detected_at"`
+}
+
+type DeadLetterResponseDTO struct {
+	ID        int64           `json:"id"`
+	LinkID    *int            `json:"link_id"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	Error     string          `json:"error"`
+	CreatedAt time.Time       `json:"created_at"`
+	FailedAt  time.Time       `json:"failed_at"`
 }
@@ -0,0 +1,199 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/config"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/repository"
+)
+
+// Notifier доставляет обновления в чаты
+type Notifier interface {
+	SendUpdate(ctx context.Context, update model.LinkUpdate) error
+}
+
+// Dispatcher периодически отправляет уведомления из outbox.
+// Доставка как минимум однократная: при падении между отправкой
+// и отметкой об отправке уведомление уйдёт повторно
+type Dispatcher struct {
+	db            repository.Repository
+	notifier      Notifier
+	log           *slog.Logger
+	interval      time.Duration
+	batchSize     int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	lease         time.Duration
+	sendTimeout   time.Duration
+	maxAttempts   int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher создаёт диспетчер outbox
+func NewDispatcher(db repository.Repository, notifier Notifier, cfg config.OutboxConfig, log *slog.Logger) *Dispatcher {
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = time.Second
+	}
+	if cfg.MaxRetryDelay < cfg.RetryDelay {
+		cfg.MaxRetryDelay = cfg.RetryDelay
+	}
+	if cfg.SendTimeout <= 0 {
+		cfg.SendTimeout = 30 * time.Second
+	}
+	// в lease должна помещаться хотя бы одна отправка с запасом
+	if cfg.Lease < 2*cfg.SendTimeout {
+		cfg.Lease = max(5*time.Minute, 2*cfg.SendTimeout)
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	return &Dispatcher{
+		db:            db,
+		notifier:      notifier,
+		log:           log,
+		interval:      cfg.Interval,
+		batchSize:     cfg.BatchSize,
+		retryDelay:    cfg.RetryDelay,
+		maxRetryDelay: cfg.MaxRetryDelay,
+		lease:         cfg.Lease,
+		sendTimeout:   cfg.SendTimeout,
+		maxAttempts:   cfg.MaxAttempts,
+	}
+}
+
+// Start запускает фоновую отправку
+func (d *Dispatcher) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			d.Dispatch(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop останавливает отправку и дожидается текущей порции
+func (d *Dispatcher) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+}
+
+// claimSize - сколько уведомлений забирать за раз: даже если каждая отправка
+// займёт весь sendTimeout, порция должна успеть до истечения lease,
+// иначе другой экземпляр заберёт её и отправит повторно
+func (d *Dispatcher) claimSize() int {
+	return max(min(d.batchSize, int(d.lease/d.sendTimeout)-1), 1)
+}
+
+// Dispatch отправляет все уведомления, для которых подошло время
+func (d *Dispatcher) Dispatch(ctx context.Context) {
+	limit := d.claimSize()
+	for ctx.Err() == nil {
+		messages, err := d.db.ClaimOutbox(ctx, limit, d.lease)
+		if err != nil {
+			d.log.Error("Can't load outbox", "err", err)
+			return
+		}
+
+		for _, msg := range messages {
+			if ctx.Err() != nil {
+				return
+			}
+			d.send(ctx, msg)
+		}
+
+		if len(messages) < limit {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, msg model.OutboxMessage) {
+	// результат отправки записывается и при остановке сервиса,
+	// иначе доставленное уведомление уйдёт повторно
+	dbCtx := context.WithoutCancel(ctx)
+
+	var update model.LinkUpdate
+	if err := json.Unmarshal(msg.Payload, &update); err != nil {
+		d.bury(dbCtx, msg, fmt.Sprintf("bad payload: %s", err))
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, d.sendTimeout)
+	err := d.notifier.SendUpdate(sendCtx, update)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			// остановка сервиса - попытка не считается, строка вернётся по истечении lease
+			return
+		}
+		if msg.Attempts+1 >= d.maxAttempts || errors.Is(err, clients.ErrPermanent) {
+			d.bury(dbCtx, msg, err.Error())
+			return
+		}
+		next := time.Now().Add(max(d.backoff(msg.Attempts), clients.RetryAfter(err)))
+		d.log.Warn("Can't send update", "id", msg.ID, "attempts", msg.Attempts+1, "next", next, "err", err)
+		if err := d.db.MarkOutboxFailed(dbCtx, msg.ID, next, err.Error()); err != nil {
+			d.log.Error("Can't update outbox", "id", msg.ID, "err", err)
+		}
+		return
+	}
+
+	if err := d.db.MarkOutboxSent(dbCtx, msg.ID); err != nil {
+		d.log.Error("Can't update outbox", "id", msg.ID, "err", err)
+	}
+}
+
+// bury переносит уведомление в dead_letters, откуда его можно отправить вручную
+func (d *Dispatcher) bury(ctx context.Context, msg model.OutboxMessage, reason string) {
+	d.log.Error("Giving up on update", "id", msg.ID, "attempts", msg.Attempts+1, "err", reason)
+	if err := d.db.MarkOutboxDead(ctx, msg.ID, reason); err != nil {
+		d.log.Error("Can't update outbox", "id", msg.ID, "err", err)
+	}
+}
+
+// backoff удваивает паузу с каждой неудачной попыткой
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.retryDelay
+	for range attempts {
+		delay *= 2
+		if delay >= d.maxRetryDelay {
+			return d.maxRetryDelay
+		}
+	}
+	return delay
+}
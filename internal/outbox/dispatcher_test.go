@@ -0,0 +1,215 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/config"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockRepository реализует только методы, нужные диспетчеру
+type mockRepository struct {
+	repository.Repository
+	mock.Mock
+}
+
+func (m *mockRepository) ClaimOutbox(_ context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error) {
+	args := m.Called(limit, lease)
+	return args.Get(0).([]model.OutboxMessage), args.Error(1)
+}
+
+func (m *mockRepository) MarkOutboxSent(_ context.Context, id int64) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *mockRepository) MarkOutboxFailed(_ context.Context, id int64, nextAttempt time.Time, reason string) error {
+	return m.Called(id, nextAttempt, reason).Error(0)
+}
+
+func (m *mockRepository) MarkOutboxDead(_ context.Context, id int64, reason string) error {
+	return m.Called(id, reason).Error(0)
+}
+
+type fakeNotifier struct {
+	// ошибки по URL обновления
+	errs map[string]error
+	sent []model.LinkUpdate
+}
+
+func (f *fakeNotifier) SendUpdate(_ context.Context, update model.LinkUpdate) error {
+	if err := f.errs[update.URL]; err != nil {
+		return err
+	}
+	f.sent = append(f.sent, update)
+	return nil
+}
+
+var cfg = config.OutboxConfig{
+	Interval:      time.Second,
+	BatchSize:     2,
+	RetryDelay:    time.Second,
+	MaxRetryDelay: 5 * time.Second,
+	Lease:         time.Minute,
+	SendTimeout:   10 * time.Second,
+	MaxAttempts:   4,
+}
+
+func message(id int64, url string, attempts int) model.OutboxMessage {
+	return model.OutboxMessage{
+		ID:       id,
+		LinkID:   1,
+		Payload:  []byte(`{"id":1,"url":"` + url + `","description":"issue","tgChatIds":[10]}`),
+		Attempts: attempts,
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	repo := new(mockRepository)
+	repo.On("ClaimOutbox", 2, time.Minute).Return([]model.OutboxMessage{
+		message(1, "https://a", 0),
+		message(2, "https://down", 2),
+	}, nil).Once()
+	repo.On("ClaimOutbox", 2, time.Minute).Return([]model.OutboxMessage{
+		message(3, "https://b", 0),
+	}, nil).Once()
+	repo.On("MarkOutboxSent", int64(1)).Return(nil)
+	repo.On("MarkOutboxSent", int64(3)).Return(nil)
+
+	start := time.Now()
+	repo.On("MarkOutboxFailed", int64(2), mock.MatchedBy(func(next time.Time) bool {
+		// третья неудача подряд - пауза 4 секунды
+		delay := next.Sub(start)
+		return delay >= 4*time.Second && delay < 5*time.Second
+	}), "bot is down").Return(nil)
+
+	notifier := &fakeNotifier{errs: map[string]error{"https://down": errors.New("bot is down")}}
+	d := NewDispatcher(repo, notifier, cfg, nil)
+	d.Dispatch(context.Background())
+
+	assert.Len(t, notifier.sent, 2)
+	assert.Equal(t, model.LinkUpdate{ID: 1, URL: "https://a", Description: "issue", TgChatIDs: []int{10}}, notifier.sent[0])
+	repo.AssertExpectations(t)
+}
+
+func TestDispatchDeadLetters(t *testing.T) {
+	repo := new(mockRepository)
+	repo.On("ClaimOutbox", 2, time.Minute).Return([]model.OutboxMessage{
+		message(1, "https://down", 3),
+		{ID: 2, Payload: []byte(`not json`)},
+		message(3, "https://blocked", 0),
+	}, nil).Once()
+	repo.On("ClaimOutbox", 2, time.Minute).Return([]model.OutboxMessage{}, nil).Once()
+	repo.On("MarkOutboxDead", int64(1), "bot is down").Return(nil)
+	repo.On("MarkOutboxDead", int64(2), mock.MatchedBy(func(reason string) bool {
+		return strings.HasPrefix(reason, "bad payload")
+	})).Return(nil)
+	// постоянная ошибка - без повторов, даже если попытки не исчерпаны
+	repo.On("MarkOutboxDead", int64(3), "chat 10: permanent delivery error").Return(nil)
+
+	notifier := &fakeNotifier{errs: map[string]error{
+		"https://down":    errors.New("bot is down"),
+		"https://blocked": fmt.Errorf("chat 10: %w", clients.ErrPermanent),
+	}}
+	d := NewDispatcher(repo, notifier, cfg, nil)
+	d.Dispatch(context.Background())
+
+	assert.Empty(t, notifier.sent)
+	repo.AssertExpectations(t)
+}
+
+// rateLimited - ответ получателя 429 с просьбой подождать
+type rateLimited struct{ wait time.Duration }
+
+func (e rateLimited) Error() string             { return "too many requests" }
+func (e rateLimited) RetryAfter() time.Duration { return e.wait }
+
+func TestDispatchRetryAfter(t *testing.T) {
+	repo := new(mockRepository)
+	repo.On("ClaimOutbox", 2, time.Minute).Return([]model.OutboxMessage{message(1, "https://busy", 0)}, nil).Once()
+
+	start := time.Now()
+	repo.On("MarkOutboxFailed", int64(1), mock.MatchedBy(func(next time.Time) bool {
+		// пауза получателя длиннее первой паузы backoff
+		return next.Sub(start) >= 30*time.Second
+	}), "too many requests").Return(nil)
+
+	notifier := &fakeNotifier{errs: map[string]error{"https://busy": rateLimited{30 * time.Second}}}
+	d := NewDispatcher(repo, notifier, cfg, nil)
+	d.Dispatch(context.Background())
+
+	repo.AssertExpectations(t)
+}
+
+func TestClaimSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		batchSize   int
+		lease       time.Duration
+		sendTimeout time.Duration
+		want        int
+	}{
+		{name: "batch fits into lease", batchSize: 10, lease: 5 * time.Minute, sendTimeout: 10 * time.Second, want: 10},
+		{name: "batch is cut to lease", batchSize: 100, lease: 5 * time.Minute, sendTimeout: 30 * time.Second, want: 9},
+		{name: "short lease is extended", batchSize: 100, lease: time.Second, sendTimeout: time.Minute, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDispatcher(nil, nil, config.OutboxConfig{
+				BatchSize:   tt.batchSize,
+				Lease:       tt.lease,
+				SendTimeout: tt.sendTimeout,
+			}, nil)
+			assert.Equal(t, tt.want, d.claimSize())
+			// худший случай - каждая отправка до таймаута - укладывается в lease
+			assert.Less(t, time.Duration(d.claimSize())*d.sendTimeout, d.lease)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, nil, cfg, nil)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 5 * time.Second},
+		{30, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, d.backoff(tt.attempts), "attempts=%d", tt.attempts)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	repo := new(mockRepository)
+	repo.On("ClaimOutbox", 2, time.Minute).Return([]model.OutboxMessage{}, nil)
+
+	d := NewDispatcher(repo, &fakeNotifier{}, config.OutboxConfig{
+		Interval:    10 * time.Millisecond,
+		BatchSize:   2,
+		Lease:       time.Minute,
+		SendTimeout: time.Second,
+	}, nil)
+	d.Start(context.Background())
+	time.Sleep(35 * time.Millisecond)
+	d.Stop()
+
+	calls := len(repo.Calls)
+	assert.GreaterOrEqual(t, calls, 2)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, calls, len(repo.Calls))
+}
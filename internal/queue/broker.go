@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrClosed - брокер закрыт
+	ErrClosed = errors.New("queue: broker is closed")
+	// ErrFull - у топика нет подписчиков и буфер сообщений заполнен
+	ErrFull = errors.New("queue: topic buffer is full")
+)
+
+// Message - сообщение в топике
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// Broker публикует сообщения в топики. Пока есть только встроенный MemoryBroker,
+// адаптер внешнего брокера (Kafka) должен реализовать этот же интерфейс.
+// Publish возвращает nil только после того, как брокер принял сообщение:
+// до этого уведомление в outbox не считается отправленным
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// MemoryBroker - брокер внутри процесса только для локальной разработки и тестов:
+// сообщения не переживают перезапуск. Раздаёт сообщения подписчикам топика,
+// а сообщения топика без подписчиков хранит в буфере ограниченного размера
+type MemoryBroker struct {
+	mu          sync.Mutex
+	closed      bool
+	capacity    int
+	messages    map[string][]Message
+	subscribers map[string][]chan Message
+}
+
+// NewMemoryBroker создаёт встроенный брокер, который хранит до capacity
+// сообщений каждого топика без подписчиков
+func NewMemoryBroker(capacity int) *MemoryBroker {
+	return &MemoryBroker{
+		capacity:    capacity,
+		messages:    make(map[string][]Message),
+		subscribers: make(map[string][]chan Message),
+	}
+}
+
+// Publish передаёт сообщение всем подписчикам топика, а если их нет - сохраняет в буфер.
+// Сообщение получают либо все подписчики, либо никто: если канал хотя бы одного
+// подписчика заполнен, Publish сразу возвращает ErrFull и outbox повторит его позже.
+// Поэтому Publish никогда не ждёт подписчика и не держит блокировку
+// дольше, чем нужно на проверку каналов
+func (b *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+	subs := b.subscribers[msg.Topic]
+	if len(subs) == 0 {
+		if len(b.messages[msg.Topic]) >= b.capacity {
+			return ErrFull
+		}
+		b.messages[msg.Topic] = append(b.messages[msg.Topic], msg)
+		return nil
+	}
+	// в каналы пишет только Publish под b.mu, так что место не пропадёт до отправки
+	for _, ch := range subs {
+		if len(ch) == cap(ch) {
+			return ErrFull
+		}
+	}
+	for _, ch := range subs {
+		ch <- msg
+	}
+	return nil
+}
+
+// Subscribe возвращает канал с новыми сообщениями топика, в котором помещается
+// buffer непрочитанных сообщений (не меньше одного). Канал закрывается при закрытии брокера
+func (b *MemoryBroker) Subscribe(topic string, buffer int) <-chan Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	buffer = max(buffer, 1)
+
+	ch := make(chan Message, buffer)
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers[topic] = append(b.subscribers[topic], ch)
+	return ch
+}
+
+// Messages возвращает сообщения топика, сохранённые в буфере
+func (b *MemoryBroker) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Message(nil), b.messages[topic]...)
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, subs := range b.subscribers {
+		for _, ch := range subs {
+			close(ch)
+		}
+	}
+	b.subscribers = nil
+	return nil
+}
@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+// Producer публикует обновления в топик брокера.
+// Обновления, не прошедшие проверку, уходят в топик недоставленных сообщений
+type Producer struct {
+	broker          Broker
+	topic           string
+	deadLetterTopic string
+}
+
+// NewProducer создаёт продюсер обновлений
+func NewProducer(broker Broker, topic, deadLetterTopic string) *Producer {
+	return &Producer{broker: broker, topic: topic, deadLetterTopic: deadLetterTopic}
+}
+
+// SendUpdate публикует обновление. Ключ сообщения - id ссылки,
+// чтобы обновления одной ссылки попадали в одну партицию и не перемешивались
+func (p *Producer) SendUpdate(ctx context.Context, update model.LinkUpdate) error {
+	value, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	msg := Message{
+		Topic: p.topic,
+		Key:   []byte(strconv.Itoa(update.ID)),
+		Value: value,
+	}
+
+	if err := Validate(update); err != nil {
+		// повторять бессмысленно - сохраняем сообщение для разбора
+		msg.Topic = p.deadLetterTopic
+		msg.Headers = map[string]string{"error": err.Error()}
+	}
+	if err := p.broker.Publish(ctx, msg); err != nil {
+		return fmt.Errorf("queue: publish to %s: %w", msg.Topic, err)
+	}
+	return nil
+}
+
+// Validate проверяет, что обновление можно доставить
+func Validate(update model.LinkUpdate) error {
+	var errs []error
+	if update.ID <= 0 {
+		errs = append(errs, errors.New("id must be positive"))
+	}
+	if u, err := clients.ParseURL(update.URL); err != nil || u.Host == "" {
+		errs = append(errs, fmt.Errorf("invalid url %q", update.URL))
+	}
+	if update.Description == "" {
+		errs = append(errs, errors.New("description is empty"))
+	}
+	if len(update.TgChatIDs) == 0 {
+		errs = append(errs, errors.New("no chats to notify"))
+	}
+	return errors.Join(errs...)
+}
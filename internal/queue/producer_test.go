@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendUpdate(t *testing.T) {
+	valid := model.LinkUpdate{ID: 7, URL: "github.com/foo/bar", Description: "issue: Bug", TgChatIDs: []int{10, 20}}
+
+	tests := []struct {
+		name       string
+		update     model.LinkUpdate
+		wantTopic  string
+		wantHeader string
+	}{
+		{name: "valid update", update: valid, wantTopic: "updates"},
+		{
+			name:       "no chats",
+			update:     model.LinkUpdate{ID: 7, URL: "https://github.com/foo/bar", Description: "issue"},
+			wantTopic:  "updates-dlq",
+			wantHeader: "no chats to notify",
+		},
+		{
+			name:       "bad url and id",
+			update:     model.LinkUpdate{URL: "://", Description: "issue", TgChatIDs: []int{1}},
+			wantTopic:  "updates-dlq",
+			wantHeader: "id must be positive\ninvalid url \"://\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := NewMemoryBroker(10)
+			p := NewProducer(broker, "updates", "updates-dlq")
+
+			require.NoError(t, p.SendUpdate(context.Background(), tt.update))
+
+			msgs := broker.Messages(tt.wantTopic)
+			require.Len(t, msgs, 1)
+			assert.Equal(t, tt.wantHeader, msgs[0].Headers["error"])
+
+			var got model.LinkUpdate
+			require.NoError(t, json.Unmarshal(msgs[0].Value, &got))
+			assert.Equal(t, tt.update, got)
+
+			if tt.wantTopic == "updates" {
+				assert.Equal(t, "7", string(msgs[0].Key))
+				assert.Empty(t, broker.Messages("updates-dlq"))
+			} else {
+				assert.Empty(t, broker.Messages("updates"))
+			}
+		})
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	broker := NewMemoryBroker(1)
+	sub := broker.Subscribe("updates", 1)
+
+	require.NoError(t, broker.Publish(context.Background(), Message{Topic: "updates", Value: []byte("1")}))
+	require.NoError(t, broker.Publish(context.Background(), Message{Topic: "other", Value: []byte("2")}))
+	assert.Equal(t, []byte("1"), (<-sub).Value)
+	assert.Empty(t, broker.Messages("updates"), "delivered messages are not buffered")
+
+	// у топика нет подписчиков, буфер заполнен - сообщение не принято
+	assert.ErrorIs(t, broker.Publish(context.Background(), Message{Topic: "other", Value: []byte("3")}), ErrFull)
+	assert.Len(t, broker.Messages("other"), 1)
+
+	// подписчик не читает - сообщение не принято, а не ждёт его
+	require.NoError(t, broker.Publish(context.Background(), Message{Topic: "updates"}))
+	assert.ErrorIs(t, broker.Publish(context.Background(), Message{Topic: "updates"}), ErrFull)
+
+	require.NoError(t, broker.Close())
+	assert.ErrorIs(t, broker.Publish(context.Background(), Message{Topic: "updates"}), ErrClosed)
+	<-sub
+	_, ok := <-sub
+	assert.False(t, ok)
+}
+
+func TestMemoryBrokerAllOrNothing(t *testing.T) {
+	broker := NewMemoryBroker(1)
+	fast := broker.Subscribe("updates", 2)
+	slow := broker.Subscribe("updates", 1)
+
+	require.NoError(t, broker.Publish(context.Background(), Message{Topic: "updates", Value: []byte("1")}))
+	// slow не прочитал первое сообщение - второе не получает никто
+	assert.ErrorIs(t, broker.Publish(context.Background(), Message{Topic: "updates", Value: []byte("2")}), ErrFull)
+	assert.Len(t, fast, 1)
+
+	// медленный подписчик не мешает другим топикам
+	require.NoError(t, broker.Publish(context.Background(), Message{Topic: "other"}))
+
+	<-slow
+	require.NoError(t, broker.Publish(context.Background(), Message{Topic: "updates", Value: []byte("2")}))
+	assert.Equal(t, []byte("1"), (<-fast).Value)
+	assert.Equal(t, []byte("2"), (<-fast).Value)
+	assert.Equal(t, []byte("2"), (<-slow).Value)
+}
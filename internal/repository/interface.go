@@ -1,13 +1,31 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"github.com/grigory222/scraptor/internal/model"
 )
 
 type Repository interface {
-	AddChat(id int) error
-	DeleteTgChat(id int) error
-	AddLink(link, tag string, tokenID, chatID int) (*model.Link, error)
-	GetLinks(chatID int) ([]model.Link, error)
-	DeleteLink(chatID int, link string) (*model.Link, error)
+	AddChat(ctx context.Context, id int) error
+	DeleteTgChat(ctx context.Context, id int) error
+	AddLink(ctx context.Context, link model.Link, chatID int) (*model.Link, error)
+	GetLinks(ctx context.Context, chatID int, tags []string) ([]model.Link, error)
+	DeleteLink(ctx context.Context, chatID int, link string) (*model.Link, error)
+	UpdateLinkSettings(ctx context.Context, chatID int, link string, status *string, checkInterval *int) (*model.Link, error)
+	GetActiveLinks(ctx context.Context, afterID, limit int) ([]model.Link, error)
+	UpdateLinkState(ctx context.Context, link model.Link, updates []model.Update, notifications []model.LinkUpdate) error
+	GetLinkUpdates(ctx context.Context, chatID, linkID, limit, offset int) ([]model.HistoryEntry, error)
+	GetLinkChats(ctx context.Context, linkID int) ([]model.LinkChat, error)
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error)
+	MarkOutboxSent(ctx context.Context, id int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, nextAttempt time.Time, reason string) error
+	MarkOutboxDead(ctx context.Context, id int64, reason string) error
+	GetDeadLetters(ctx context.Context, limit, offset int) ([]model.DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, id int64) error
+	AddToken(ctx context.Context, chatID int, name, token string) (*model.Token, error)
+	GetTokens(ctx context.Context, chatID int) ([]model.Token, error)
+	DeleteToken(ctx context.Context, chatID, id int) (*model.Token, error)
+	GetToken(ctx context.Context, id int) (string, error)
 }
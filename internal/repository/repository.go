@@ -1,40 +1,56 @@
 package repository
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/grigory222/scraptor/internal/config"
 	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/secrets"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound - запись не найдена
+	ErrNotFound = errors.New("not found")
+	// ErrTokenInUse - токен используют ссылки
+	ErrTokenInUse = errors.New("token is used by links")
 )
 
 type Postgres struct {
-	DB  *sqlx.DB
-	log *slog.Logger
+	DB *sqlx.DB
+	// ключи шифрования токенов; nil - новые токены сохранять нельзя
+	keys *secrets.Keyring
+	log  *slog.Logger
 }
 
-func NewPostgres(cfg config.DBConfig, log *slog.Logger) *Postgres {
+func NewPostgres(cfg config.DBConfig, keys *secrets.Keyring, log *slog.Logger) *Postgres {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Host, cfg.User, cfg.Password, cfg.DBName)
 	db, err := sqlx.Connect("postgres", dsn)
 	if err != nil {
 		log.Error(err.Error())
 	}
-	return &Postgres{DB: db, log: log}
+	return &Postgres{DB: db, keys: keys, log: log}
 }
 
 // ================= Chats =================
 
-func (p *Postgres) AddChat(id int) error {
+func (p *Postgres) AddChat(ctx context.Context, id int) error {
 	// на данный момент только лс с ботом
 	chatType := "personal"
 
 	query := `INSERT INTO chats (id, type) VALUES ($1, $2)`
 
-	_, err := p.DB.Exec(query, id, chatType)
+	_, err := p.DB.ExecContext(ctx, query, id, chatType)
 	if err != nil {
 		return err
 	}
@@ -42,10 +58,10 @@ func (p *Postgres) AddChat(id int) error {
 	return nil
 }
 
-// func (p *Postgres) GetTgChat(id int) (*model.Chat, error) {
+// func (p *Postgres) GetTgChat(ctx context.Context, id int) (*model.Chat, error) {
 // 	query := `SELECT id, type FROM chats WHERE id = $1`
 // 	chat := model.Chat{}
-// 	err := p.DB.Get(&chat, query, id)
+// 	err := p.DB.GetContext(ctx, &chat, query, id)
 // 	if err != nil {
 // 		p.log.Error("Can't select chat", "id", id, "err", err)
 // 		return nil, err
@@ -53,75 +69,142 @@ func (p *Postgres) AddChat(id int) error {
 // 	return &chat, nil
 // }
 
-func (p *Postgres) DeleteTgChat(id int) error {
-	query := `DELETE FROM chats WHERE id = $1`
-	res, err := p.DB.Exec(query, id)
+// DeleteTgChat удаляет чат вместе с подписками, тегами и токенами.
+// Ссылки, на которые больше никто не подписан, удаляются до чата: иначе ссылка
+// с токеном чата помешала бы каскадному удалению токена
+func (p *Postgres) DeleteTgChat(ctx context.Context, id int) error {
+	tx, err := p.DB.BeginTxx(ctx, nil)
 	if err != nil {
-		p.log.Error(err.Error())
 		return err
 	}
-	rows, _ := res.RowsAffected()
-	if rows < 1 {
+	defer tx.Rollback()
+
+	var chatID int
+	err = tx.GetContext(ctx, &chatID, `SELECT id FROM chats WHERE id = $1 FOR UPDATE`, id)
+	if errors.Is(err, sql.ErrNoRows) {
 		return fmt.Errorf("nothing deleted")
 	}
-	return nil
+	if err != nil {
+		p.log.Error(err.Error())
+		return err
+	}
+
+	// заблокировать ссылки чата, чтобы параллельный AddLink не подписал другой чат на удаляемую строку
+	query := `SELECT id FROM links
+			  WHERE id IN (SELECT link_id FROM chats_links WHERE chat_id = $1)
+			  ORDER BY id
+			  FOR UPDATE`
+	var linkIDs []int64
+	if err := tx.SelectContext(ctx, &linkIDs, query, id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chats_links WHERE chat_id = $1`, id); err != nil {
+		return err
+	}
+
+	query = `DELETE FROM links l
+			  WHERE l.id = ANY($1) AND NOT EXISTS (SELECT 1 FROM chats_links WHERE link_id = l.id)`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(linkIDs)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chats WHERE id = $1`, id); err != nil {
+		p.log.Error(err.Error())
+		return err
+	}
+	return tx.Commit()
 }
 
 // ================= Links =================
 
-// Дублирование элементов сделано намерено,
-// поскольку за каждой ссылкой закреплен tokenID,
-// который у каждого свой соответственно
-
-func (p *Postgres) AddLink(link string, tag string, tokenID int, chatID int) (*model.Link, error) {
-	linkFound, err := p.GetLink(chatID, link)
+// AddLink подписывает чат на ссылку. Одинаковые ссылки разных чатов (тот же URL,
+// источник, селектор и токен) делят одну строку links и проверяются один раз
+func (p *Postgres) AddLink(ctx context.Context, link model.Link, chatID int) (*model.Link, error) {
+	linkFound, err := p.GetLink(ctx, chatID, link.Link)
 	if err != nil || linkFound != nil {
 		return nil, err
 	}
-	p.log.Debug("here0")
 
 	// Начинаем транзакцию
-	tx, err := p.DB.Beginx()
+	tx, err := p.DB.BeginTxx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Вставляем запись в таблицу links
-	query := `INSERT INTO links (link, tag, token_id) VALUES ($1, $2, $3) RETURNING id`
-	var newID int
-	p.log.Debug("", "tokenID", tokenID)
-	if tokenID == 0 {
-		err = tx.Get(&newID, query, link, tag, nil)
-	} else {
-		err = tx.Get(&newID, query, link, tag, tokenID)
-	}
+	// Находим или создаём запись в таблице links. DO UPDATE нужен, чтобы
+	// RETURNING вернул id существующей строки и заблокировал её до конца транзакции
+	query := `INSERT INTO links (link, kind, selector, token_id) VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (link, kind, selector, (COALESCE(token_id, 0))) DO UPDATE SET link = EXCLUDED.link
+			  RETURNING id`
+	err = tx.GetContext(ctx, &link.ID, query, link.Link, link.Kind, link.Selector, link.TokenID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Вставляем запись в таблицу chats_links
-	insertChatLinkQuery := `INSERT INTO chats_links (chat_id, link_id) VALUES ($1, $2)`
-	_, err = tx.Exec(insertChatLinkQuery, chatID, newID)
+	if link.Filters == nil {
+		link.Filters = pq.StringArray{}
+	}
+	insertChatLinkQuery := `INSERT INTO chats_links (chat_id, link_id, status, filters, original_link)
+							VALUES ($1, $2, 'active', $3, NULLIF($4, ''))`
+	_, err = tx.ExecContext(ctx, insertChatLinkQuery, chatID, link.ID, link.Filters, link.Original)
 	if err != nil {
 		return nil, err
 	}
 
+	// Теги чата создаются при первом использовании
+	for _, tag := range link.Tags {
+		var tagID int
+		query = `INSERT INTO tags (chat_id, name) VALUES ($1, $2)
+				 ON CONFLICT (chat_id, name) DO UPDATE SET name = EXCLUDED.name
+				 RETURNING id`
+		err = tx.GetContext(ctx, &tagID, query, chatID, tag)
+		if err != nil {
+			return nil, err
+		}
+		query = `INSERT INTO links_tags (chat_id, link_id, tag_id) VALUES ($1, $2, $3)`
+		_, err = tx.ExecContext(ctx, query, chatID, link.ID, tagID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Если все успешно, коммитим транзакцию
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
 
-	return model.NewLink(newID, link, tag, tokenID), nil
+	link.Status = model.StatusActive
+	return &link, nil
 }
 
-func (p *Postgres) GetLinks(chatID int) ([]model.Link, error) {
-	query := `SELECT links.id, links.link, links.tag, links.token_id FROM links 
-			  JOIN chats_links on links.id = chats_links.link_id
-			  WHERE chats_links.chat_id = $1`
+// chatLinkTags - теги ссылки в чате, для запросов с chats_links под псевдонимом cl
+const chatLinkTags = `ARRAY(
+	SELECT t.name FROM links_tags lt
+	JOIN tags t ON t.id = lt.tag_id
+	WHERE lt.chat_id = cl.chat_id AND lt.link_id = cl.link_id
+	ORDER BY t.name
+) AS tags`
+
+// GetLinks возвращает ссылки чата. Если заданы tags - только ссылки,
+// помеченные хотя бы одним из них
+func (p *Postgres) GetLinks(ctx context.Context, chatID int, tags []string) ([]model.Link, error) {
+	query := `SELECT links.id, links.link, COALESCE(cl.original_link, links.link) AS original_link,
+			         links.kind, links.selector, links.token_id,
+			         cl.status, cl.check_interval, cl.filters, ` + chatLinkTags + `
+			  FROM links
+			  JOIN chats_links cl on links.id = cl.link_id
+			  WHERE cl.chat_id = $1 AND (COALESCE(cardinality($2::TEXT[]), 0) = 0 OR EXISTS (
+			      SELECT 1 FROM links_tags lt
+			      JOIN tags t ON t.id = lt.tag_id
+			      WHERE lt.chat_id = cl.chat_id AND lt.link_id = cl.link_id AND t.name = ANY($2)
+			  ))
+			  ORDER BY links.id`
 	var links []model.Link
-	err := p.DB.Select(&links, query, chatID)
+	err := p.DB.SelectContext(ctx, &links, query, chatID, pq.StringArray(tags))
 	if err != nil {
 		return nil, err
 	}
@@ -129,13 +212,15 @@ func (p *Postgres) GetLinks(chatID int) ([]model.Link, error) {
 	return links, nil
 }
 
-func (p *Postgres) GetLink(chatID int, link string) (*model.Link, error) {
-	query := `SELECT links.id, links.link, links.tag, links.token_id FROM links
+func (p *Postgres) GetLink(ctx context.Context, chatID int, link string) (*model.Link, error) {
+	query := `SELECT links.id, links.link, COALESCE(cl.original_link, links.link) AS original_link,
+			         links.kind, links.selector, links.token_id,
+			         cl.status, cl.check_interval, cl.filters, ` + chatLinkTags + `
+			  FROM links
 			  JOIN chats_links cl on cl.link_id = links.id
 			  WHERE cl.chat_id = $1 and links.link = $2`
 	var linkRes model.Link
-	err := p.DB.Get(&linkRes, query, chatID, link)
-	p.log.Debug("herrr")
+	err := p.DB.GetContext(ctx, &linkRes, query, chatID, link)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -147,39 +232,40 @@ func (p *Postgres) GetLink(chatID int, link string) (*model.Link, error) {
 	return &linkRes, nil
 }
 
-func (p *Postgres) DeleteLink(chatID int, link string) (*model.Link, error) {
-	linkFound, err := p.GetLink(chatID, link)
+func (p *Postgres) DeleteLink(ctx context.Context, chatID int, link string) (*model.Link, error) {
+	linkFound, err := p.GetLink(ctx, chatID, link)
 
 	if err != nil {
 		return nil, err
 	}
 	if linkFound == nil {
-		return nil, fmt.Errorf("not found such link")
+		return nil, ErrNotFound
 	}
 
 	// начать транзакцию
-	tx, err := p.DB.Beginx()
+	tx, err := p.DB.BeginTxx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// удалить ссылку
-	query := `DELETE FROM links
-			  WHERE links.id = $1`
-	_, err = tx.Exec(query, linkFound.ID)
-	if err != nil {
+	// заблокировать ссылку, чтобы параллельный AddLink не подписал чат на удаляемую строку
+	query := `SELECT id FROM links WHERE id = $1 FOR UPDATE`
+	if _, err = tx.ExecContext(ctx, query, linkFound.ID); err != nil {
 		return nil, err
 	}
 
-	// удалить её токен
-	query = `DELETE FROM tokens
-			  WHERE tokens.id = $1`
-	if linkFound.TokenID != nil {
-		_, err := tx.Exec(query, *linkFound.TokenID)
-		if err != nil {
-			return nil, err
-		}
+	// отписать чат
+	query = `DELETE FROM chats_links WHERE chat_id = $1 AND link_id = $2`
+	if _, err = tx.ExecContext(ctx, query, chatID, linkFound.ID); err != nil {
+		return nil, err
+	}
+
+	// удалить ссылку вместе с историей, если её больше не отслеживает ни один чат
+	query = `DELETE FROM links
+			  WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM chats_links WHERE link_id = $1)`
+	if _, err = tx.ExecContext(ctx, query, linkFound.ID); err != nil {
+		return nil, err
 	}
 
 	// завершить транзакцию
@@ -189,3 +275,547 @@ func (p *Postgres) DeleteLink(chatID int, link string) (*model.Link, error) {
 	}
 	return linkFound, nil
 }
+
+// UpdateLinkSettings меняет статус и интервал проверки ссылки в чате.
+// nil не меняет значение, checkInterval = 0 сбрасывает интервал на значение по умолчанию
+func (p *Postgres) UpdateLinkSettings(ctx context.Context, chatID int, link string, status *string, checkInterval *int) (*model.Link, error) {
+	query := `UPDATE chats_links cl
+			  SET status = COALESCE($3, cl.status),
+			      check_interval = CASE WHEN $4::INTEGER IS NULL THEN cl.check_interval ELSE NULLIF($4, 0) END
+			  FROM links
+			  WHERE cl.link_id = links.id AND cl.chat_id = $1 AND links.link = $2`
+	res, err := p.DB.ExecContext(ctx, query, chatID, link, status, checkInterval)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNotFound
+	}
+	return p.GetLink(ctx, chatID, link)
+}
+
+// CanonicalizeLinks приводит к каноническому виду ссылки, сохранённые до канонизации.
+// Если каноническая ссылка уже есть, подписки, теги и история переносятся в неё,
+// как при слиянии дубликатов в миграции shared_links. Ссылки, для которых canonical
+// вернул ошибку, остаются как есть. Возвращает число изменённых ссылок
+func (p *Postgres) CanonicalizeLinks(ctx context.Context, canonical func(link string) (string, error)) (int, error) {
+	const batchSize = 500
+	changed := 0
+	afterID := 0
+	for {
+		var links []model.Link
+		query := `SELECT id, link FROM links WHERE id > $1 ORDER BY id LIMIT $2`
+		if err := p.DB.SelectContext(ctx, &links, query, afterID, batchSize); err != nil {
+			return changed, err
+		}
+		for _, link := range links {
+			c, err := canonical(link.Link)
+			if err != nil || c == link.Link {
+				continue
+			}
+			if err := p.canonicalizeLink(ctx, link.ID, c); err != nil {
+				return changed, fmt.Errorf("link %d: %w", link.ID, err)
+			}
+			changed++
+		}
+		if len(links) < batchSize {
+			return changed, nil
+		}
+		afterID = links[len(links)-1].ID
+	}
+}
+
+// canonicalizeLink заменяет ссылку канонической или сливает её с уже существующей
+func (p *Postgres) canonicalizeLink(ctx context.Context, id int, canonical string) error {
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var link model.Link
+	query := `SELECT id, link, kind, selector, token_id FROM links WHERE id = $1 FOR UPDATE`
+	err = tx.GetContext(ctx, &link, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// ссылка в том виде, в каком её добавили чаты
+	query = `UPDATE chats_links SET original_link = $2 WHERE link_id = $1 AND original_link IS NULL`
+	if _, err := tx.ExecContext(ctx, query, id, link.Link); err != nil {
+		return err
+	}
+
+	var keepID int
+	query = `SELECT id FROM links
+			  WHERE link = $1 AND kind = $2 AND selector = $3 AND COALESCE(token_id, 0) = COALESCE($4, 0)
+			  FOR UPDATE`
+	err = tx.GetContext(ctx, &keepID, query, canonical, link.Kind, link.Selector, link.TokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := tx.ExecContext(ctx, `UPDATE links SET link = $2 WHERE id = $1`, id, canonical); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	queries := []string{
+		`INSERT INTO chats_links (chat_id, link_id, status, check_interval, filters, original_link)
+		 SELECT chat_id, $2, status, check_interval, filters, original_link FROM chats_links WHERE link_id = $1
+		 ON CONFLICT (chat_id, link_id) DO NOTHING`,
+		`INSERT INTO links_tags (chat_id, link_id, tag_id)
+		 SELECT chat_id, $2, tag_id FROM links_tags WHERE link_id = $1
+		 ON CONFLICT DO NOTHING`,
+		`UPDATE updates SET link_id = $2 WHERE link_id = $1`,
+		`UPDATE outbox SET link_id = $2 WHERE link_id = $1`,
+		`UPDATE dead_letters SET link_id = $2 WHERE link_id = $1`,
+	}
+	for _, q := range queries {
+		if _, err := tx.ExecContext(ctx, q, id, keepID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ================= Scheduler =================
+
+// GetActiveLinks возвращает порцию ссылок, которые активно отслеживает хотя бы один чат.
+// Интервал проверки - наименьший из заданных чатами; если хоть один чат
+// не задавал интервал, берётся интервал по умолчанию (NULL).
+// Пагинация по id: следующая порция запрашивается с afterID = id последней ссылки
+func (p *Postgres) GetActiveLinks(ctx context.Context, afterID, limit int) ([]model.Link, error) {
+	query := `SELECT links.id, links.link, links.kind, links.selector, links.token_id, links.last_checked_at, links.state,
+			         intervals.check_interval
+			  FROM links
+			  JOIN LATERAL (
+			      SELECT CASE WHEN bool_or(cl.check_interval IS NULL) THEN NULL
+			                  ELSE MIN(cl.check_interval) END AS check_interval
+			      FROM chats_links cl
+			      WHERE cl.link_id = links.id AND cl.status = 'active'
+			      HAVING COUNT(*) > 0
+			  ) intervals ON true
+			  WHERE links.id > $1
+			  ORDER BY links.id
+			  LIMIT $2`
+	var links []model.Link
+	err := p.DB.SelectContext(ctx, &links, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return links, nil
+	}
+
+	ids := make([]int64, len(links))
+	for i, link := range links {
+		ids[i] = int64(link.ID)
+	}
+	validators, err := p.getValidators(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		links[i].Validators = validators[links[i].ID]
+	}
+
+	return links, nil
+}
+
+// getValidators возвращает сохранённые ETag и Last-Modified ссылок по URL запросов
+func (p *Postgres) getValidators(ctx context.Context, linkIDs []int64) (map[int]map[string]model.Validator, error) {
+	query := `SELECT link_id, url, etag, last_modified FROM link_state WHERE link_id = ANY($1)`
+	var rows []struct {
+		LinkID int    `db:"link_id"`
+		URL    string `db:"url"`
+		model.Validator
+	}
+	err := p.DB.SelectContext(ctx, &rows, query, pq.Array(linkIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	validators := make(map[int]map[string]model.Validator)
+	for _, row := range rows {
+		if validators[row.LinkID] == nil {
+			validators[row.LinkID] = make(map[string]model.Validator)
+		}
+		validators[row.LinkID][row.URL] = row.Validator
+	}
+	return validators, nil
+}
+
+// UpdateLinkState сохраняет время проверки, состояние чекера и валидаторы HTTP-кэша,
+// а в той же транзакции записывает найденные события в историю и уведомления в outbox
+func (p *Postgres) UpdateLinkState(ctx context.Context, link model.Link, updates []model.Update, notifications []model.LinkUpdate) error {
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE links SET last_checked_at = $1, state = $2 WHERE id = $3`
+	// pq передаёт []byte как bytea, поэтому JSON отдаём строкой
+	var state *string
+	if len(link.State) > 0 {
+		s := string(link.State)
+		state = &s
+	}
+	_, err = tx.ExecContext(ctx, query, link.LastCheckedAt, state, link.ID)
+	if err != nil {
+		return err
+	}
+
+	// валидаторы перезаписываются целиком: URL, которые чекер больше не запрашивает, удаляются
+	_, err = tx.ExecContext(ctx, `DELETE FROM link_state WHERE link_id = $1`, link.ID)
+	if err != nil {
+		return err
+	}
+	query = `INSERT INTO link_state (link_id, url, etag, last_modified) VALUES ($1, $2, $3, $4)`
+	for url, v := range link.Validators {
+		_, err = tx.ExecContext(ctx, query, link.ID, url, v.ETag, v.LastModified)
+		if err != nil {
+			return err
+		}
+	}
+
+	query = `INSERT INTO updates (link_id, type, title, author, url, preview, payload, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	for _, u := range updates {
+		payload, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, query, link.ID, u.Type, u.Title, u.Author, u.URL, u.Preview, string(payload), u.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	query = `INSERT INTO outbox (link_id, payload) VALUES ($1, $2)`
+	for _, notification := range notifications {
+		payload, err := json.Marshal(notification)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, query, link.ID, string(payload))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLinkUpdates возвращает историю событий ссылки, начиная с последних.
+// ErrNotFound - чат не отслеживает такую ссылку
+func (p *Postgres) GetLinkUpdates(ctx context.Context, chatID, linkID, limit, offset int) ([]model.HistoryEntry, error) {
+	var tracked bool
+	query := `SELECT EXISTS (SELECT 1 FROM chats_links WHERE chat_id = $1 AND link_id = $2)`
+	err := p.DB.GetContext(ctx, &tracked, query, chatID, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if !tracked {
+		return nil, ErrNotFound
+	}
+
+	query = `SELECT id, link_id, type, title, author, url, preview, payload, created_at, detected_at
+			 FROM updates
+			 WHERE link_id = $1
+			 ORDER BY id DESC
+			 LIMIT $2 OFFSET $3`
+	entries := []model.HistoryEntry{}
+	err = p.DB.SelectContext(ctx, &entries, query, linkID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetLinkChats возвращает чаты, которые активно отслеживают ссылку, вместе с их фильтрами
+func (p *Postgres) GetLinkChats(ctx context.Context, linkID int) ([]model.LinkChat, error) {
+	query := `SELECT chat_id, filters FROM chats_links
+			  WHERE link_id = $1 AND status = 'active'
+			  ORDER BY chat_id`
+	var chats []model.LinkChat
+	err := p.DB.SelectContext(ctx, &chats, query, linkID)
+	if err != nil {
+		return nil, err
+	}
+	return chats, nil
+}
+
+// ================= Outbox =================
+
+// ClaimOutbox забирает порцию неотправленных уведомлений, для которых подошло время.
+// Забранные строки откладываются на lease, чтобы их не взял другой экземпляр;
+// если отправка не отметится, они вернутся в очередь по истечении lease
+func (p *Postgres) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error) {
+	query := `UPDATE outbox SET next_attempt_at = now() + $2 * interval '1 millisecond'
+			  WHERE id IN (
+			      SELECT id FROM outbox
+			      WHERE next_attempt_at <= now()
+			      ORDER BY id
+			      LIMIT $1
+			      FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING id, link_id, payload, attempts`
+	var messages []model.OutboxMessage
+	err := p.DB.SelectContext(ctx, &messages, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(messages, func(a, b model.OutboxMessage) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return messages, nil
+}
+
+// MarkOutboxSent удаляет отправленное уведомление: в outbox остаются только ожидающие
+func (p *Postgres) MarkOutboxSent(ctx context.Context, id int64) error {
+	_, err := p.DB.ExecContext(ctx, `DELETE FROM outbox WHERE id = $1`, id)
+	return err
+}
+
+// MarkOutboxFailed откладывает уведомление до следующей попытки
+func (p *Postgres) MarkOutboxFailed(ctx context.Context, id int64, nextAttempt time.Time, reason string) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3 WHERE id = $1`
+	_, err := p.DB.ExecContext(ctx, query, id, nextAttempt, reason)
+	return err
+}
+
+// MarkOutboxDead переносит уведомление из outbox в dead_letters
+func (p *Postgres) MarkOutboxDead(ctx context.Context, id int64, reason string) error {
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `WITH moved AS (
+			      DELETE FROM outbox WHERE id = $1
+			      RETURNING link_id, payload, attempts, created_at
+			  )
+			  INSERT INTO dead_letters (link_id, payload, attempts, error, created_at)
+			  SELECT link_id, payload, attempts + 1, $2, created_at FROM moved`
+	_, err = tx.ExecContext(ctx, query, id, reason)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ================= Dead letters =================
+
+func (p *Postgres) GetDeadLetters(ctx context.Context, limit, offset int) ([]model.DeadLetter, error) {
+	query := `SELECT id, link_id, payload, attempts, error, created_at, failed_at
+			  FROM dead_letters
+			  ORDER BY id DESC
+			  LIMIT $1 OFFSET $2`
+	var letters []model.DeadLetter
+	err := p.DB.SelectContext(ctx, &letters, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetter возвращает уведомление в outbox с обнулённым счётчиком попыток
+func (p *Postgres) ReplayDeadLetter(ctx context.Context, id int64) error {
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `WITH replayed AS (
+			      DELETE FROM dead_letters WHERE id = $1
+			      RETURNING link_id, payload
+			  )
+			  INSERT INTO outbox (link_id, payload)
+			  SELECT link_id, payload FROM replayed`
+	res, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows < 1 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// ================= Tokens =================
+
+// tokenSecret - токен в том виде, в каком он лежит в БД
+type tokenSecret struct {
+	ID     int           `db:"id"`
+	ChatID sql.NullInt64 `db:"chat_id"`
+	// открытый текст записей, созданных до шифрования
+	Plain  sql.NullString `db:"token"`
+	KeyID  sql.NullString `db:"key_id"`
+	Secret []byte         `db:"secret"`
+}
+
+// tokenAAD - дополнительные данные AES-GCM, которые привязывают секрет к строке токена:
+// шифротекст, скопированный в другую строку или другой чат, не расшифруется.
+// У старых записей без чата chatID равен 0
+func tokenAAD(id int, chatID int64) []byte {
+	return []byte(fmt.Sprintf("tokens:%d:chat:%d", id, chatID))
+}
+
+func (p *Postgres) decrypt(t tokenSecret) (string, error) {
+	if t.Secret == nil {
+		return t.Plain.String, nil
+	}
+	plain, err := p.keys.Decrypt(t.KeyID.String, t.Secret, tokenAAD(t.ID, t.ChatID.Int64))
+	if err != nil {
+		return "", fmt.Errorf("token %d: %w", t.ID, err)
+	}
+	return string(plain), nil
+}
+
+// AddToken шифрует и сохраняет токен чата. id берётся из последовательности заранее,
+// потому что входит в дополнительные данные шифротекста
+func (p *Postgres) AddToken(ctx context.Context, chatID int, name, token string) (*model.Token, error) {
+	if p.keys == nil {
+		return nil, secrets.ErrNoKey
+	}
+
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.GetContext(ctx, &id, `SELECT nextval(pg_get_serial_sequence('tokens', 'id'))`); err != nil {
+		return nil, err
+	}
+	keyID, secret, err := p.keys.Encrypt([]byte(token), tokenAAD(id, int64(chatID)))
+	if err != nil {
+		return nil, err
+	}
+	query := `INSERT INTO tokens (id, chat_id, name, key_id, secret) VALUES ($1, $2, $3, $4, $5)
+			  RETURNING id, chat_id, name, created_at`
+	var t model.Token
+	if err := tx.GetContext(ctx, &t, query, id, chatID, name, keyID, secret); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTokens возвращает токены чата без секретов
+func (p *Postgres) GetTokens(ctx context.Context, chatID int) ([]model.Token, error) {
+	query := `SELECT id, chat_id, name, created_at FROM tokens WHERE chat_id = $1 ORDER BY id`
+	var tokens []model.Token
+	if err := p.DB.SelectContext(ctx, &tokens, query, chatID); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// DeleteToken удаляет токен чата. Токен, который используют ссылки, удалить нельзя:
+// ссылка без токена совпала бы с такой же анонимной ссылкой
+func (p *Postgres) DeleteToken(ctx context.Context, chatID, id int) (*model.Token, error) {
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var t model.Token
+	query := `SELECT id, chat_id, name, created_at FROM tokens WHERE chat_id = $1 AND id = $2 FOR UPDATE`
+	err = tx.GetContext(ctx, &t, query, chatID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var inUse bool
+	query = `SELECT EXISTS (SELECT 1 FROM links WHERE token_id = $1)`
+	if err := tx.GetContext(ctx, &inUse, query, id); err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, ErrTokenInUse
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tokens WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetToken возвращает расшифрованный токен для запроса к источнику
+func (p *Postgres) GetToken(ctx context.Context, id int) (string, error) {
+	query := `SELECT id, chat_id, token, key_id, secret FROM tokens WHERE id = $1`
+	var t tokenSecret
+	err := p.DB.GetContext(ctx, &t, query, id)
+	if err != nil {
+		return "", err
+	}
+	return p.decrypt(t)
+}
+
+// ReencryptTokens перешифровывает текущим ключом токены, зашифрованные
+// прежними ключами или сохранённые в открытом виде. Возвращает число изменённых записей
+func (p *Postgres) ReencryptTokens(ctx context.Context) (int, error) {
+	if p.keys == nil {
+		return 0, secrets.ErrNoKey
+	}
+
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, chat_id, token, key_id, secret FROM tokens
+			  WHERE key_id IS DISTINCT FROM $1
+			  FOR UPDATE`
+	var stale []tokenSecret
+	if err := tx.SelectContext(ctx, &stale, query, p.keys.CurrentID()); err != nil {
+		return 0, err
+	}
+
+	for _, t := range stale {
+		plain, err := p.decrypt(t)
+		if err != nil {
+			return 0, err
+		}
+		keyID, secret, err := p.keys.Encrypt([]byte(plain), tokenAAD(t.ID, t.ChatID.Int64))
+		if err != nil {
+			return 0, err
+		}
+		query := `UPDATE tokens SET token = NULL, key_id = $2, secret = $3 WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, t.ID, keyID, secret); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(stale), nil
+}
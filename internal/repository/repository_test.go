@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	slogpretty "github.com/grigory222/scraptor/internal/logger"
+	"github.com/grigory222/scraptor/internal/migrate"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/secrets"
+	"github.com/grigory222/scraptor/internal/testdb"
+	"github.com/grigory222/scraptor/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPostgres возвращает репозиторий над отдельной схемой с применёнными миграциями
+func newTestPostgres(t *testing.T) *Postgres {
+	t.Helper()
+	db := testdb.New(t)
+	m, err := migrate.New(db, migrations.FS, nil)
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	keys, err := secrets.NewKeyring(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", secrets.KeySize))))
+	require.NoError(t, err)
+	return &Postgres{DB: db, keys: keys, log: slogpretty.NewLogger()}
+}
+
+func TestDeleteTgChat(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+
+	require.NoError(t, p.AddChat(ctx, 1))
+	require.NoError(t, p.AddChat(ctx, 2))
+
+	token, err := p.AddToken(ctx, 1, "github", "ghp_secret")
+	require.NoError(t, err)
+	private, err := p.AddLink(ctx, model.Link{Link: "https://github.com/foo/private", Kind: "github", TokenID: &token.ID}, 1)
+	require.NoError(t, err)
+	shared, err := p.AddLink(ctx, model.Link{Link: "https://github.com/foo/bar", Kind: "github"}, 1)
+	require.NoError(t, err)
+	_, err = p.AddLink(ctx, model.Link{Link: "https://github.com/foo/bar", Kind: "github"}, 2)
+	require.NoError(t, err)
+
+	require.NoError(t, p.DeleteTgChat(ctx, 1))
+
+	var count int
+	require.NoError(t, p.DB.Get(&count, `SELECT count(*) FROM links WHERE id = $1`, private.ID))
+	assert.Zero(t, count, "link nobody tracks must be deleted")
+	require.NoError(t, p.DB.Get(&count, `SELECT count(*) FROM tokens WHERE id = $1`, token.ID))
+	assert.Zero(t, count, "chat tokens must be deleted")
+
+	links, err := p.GetLinks(ctx, 2, nil)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, shared.ID, links[0].ID)
+
+	assert.Error(t, p.DeleteTgChat(ctx, 1))
+}
+
+func TestCanonicalizeLinks(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+
+	// ссылки, сохранённые до канонизации
+	_, err := p.DB.Exec(`
+		INSERT INTO chats (id, type) VALUES (1, 'personal'), (2, 'personal');
+		INSERT INTO links (id, link) VALUES (1, 'HTTPS://Example.com/a/'), (2, 'https://example.com/a'), (3, 'https://Example.com/b');
+		INSERT INTO chats_links (chat_id, link_id) VALUES (1, 1), (2, 2), (1, 3);
+		INSERT INTO tags (id, chat_id, name) VALUES (1, 1, 'work');
+		INSERT INTO links_tags (chat_id, link_id, tag_id) VALUES (1, 1, 1);
+		INSERT INTO updates (link_id, type, payload, created_at) VALUES (1, 'issue', '{}', now());
+	`)
+	require.NoError(t, err)
+
+	n, err := p.CanonicalizeLinks(ctx, func(link string) (string, error) {
+		return strings.TrimRight(strings.ToLower(link), "/"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	links, err := p.GetLinks(ctx, 1, nil)
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	// дубликат слит с канонической ссылкой вместе с тегами
+	assert.Equal(t, 2, links[0].ID)
+	assert.Equal(t, "https://example.com/a", links[0].Link)
+	assert.Equal(t, "HTTPS://Example.com/a/", links[0].Original)
+	assert.Equal(t, []string{"work"}, []string(links[0].Tags))
+	// ссылка без дубликата переименована
+	assert.Equal(t, 3, links[1].ID)
+	assert.Equal(t, "https://example.com/b", links[1].Link)
+	assert.Equal(t, "https://Example.com/b", links[1].Original)
+
+	var updates int
+	require.NoError(t, p.DB.Get(&updates, `SELECT count(*) FROM updates WHERE link_id = 2`))
+	assert.Equal(t, 1, updates)
+
+	n, err = p.CanonicalizeLinks(ctx, func(link string) (string, error) {
+		return strings.TrimRight(strings.ToLower(link), "/"), nil
+	})
+	require.NoError(t, err)
+	assert.Zero(t, n, "canonical links are left as is")
+}
+
+func TestTokenSecretBoundToRow(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+
+	require.NoError(t, p.AddChat(ctx, 1))
+	require.NoError(t, p.AddChat(ctx, 2))
+	mine, err := p.AddToken(ctx, 1, "github", "ghp_mine")
+	require.NoError(t, err)
+	other, err := p.AddToken(ctx, 2, "github", "ghp_other")
+	require.NoError(t, err)
+
+	token, err := p.GetToken(ctx, mine.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_mine", token)
+
+	// секрет чужого токена, скопированный в свою строку, не расшифровывается
+	_, err = p.DB.Exec(`UPDATE tokens SET key_id = o.key_id, secret = o.secret
+		FROM tokens o WHERE tokens.id = $1 AND o.id = $2`, mine.ID, other.ID)
+	require.NoError(t, err)
+	_, err = p.GetToken(ctx, mine.ID)
+	assert.Error(t, err)
+}
+
+func TestMarkOutboxSent(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+
+	_, err := p.DB.Exec(`INSERT INTO outbox (payload) VALUES ('{}'), ('{}')`)
+	require.NoError(t, err)
+	messages, err := p.ClaimOutbox(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	require.NoError(t, p.MarkOutboxSent(ctx, messages[0].ID))
+
+	var ids []int64
+	require.NoError(t, p.DB.Select(&ids, `SELECT id FROM outbox`))
+	assert.Equal(t, []int64{messages[1].ID}, ids, "sent messages must not pile up in outbox")
+}
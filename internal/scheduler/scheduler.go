@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/config"
+	"github.com/grigory222/scraptor/internal/filters"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/grigory222/scraptor/internal/sources"
+)
+
+// Scheduler периодически обходит активные ссылки, передаёт их
+// соответствующим источникам и кладёт найденные события в outbox
+type Scheduler struct {
+	db        repository.Repository
+	sources   *sources.Registry
+	log       *slog.Logger
+	interval  time.Duration
+	batchSize int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler создаёт планировщик
+func NewScheduler(db repository.Repository, registry *sources.Registry, cfg config.SchedulerConfig, log *slog.Logger) *Scheduler {
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &Scheduler{
+		db:        db,
+		sources:   registry,
+		log:       log,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+	}
+}
+
+// Start запускает фоновый обход ссылок
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.CheckAll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop останавливает планировщик и ждёт завершения текущего обхода
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// CheckAll проверяет активные ссылки, у которых подошло время проверки, порциями по batchSize
+func (s *Scheduler) CheckAll(ctx context.Context) {
+	now := time.Now()
+	afterID := 0
+	for ctx.Err() == nil {
+		links, err := s.db.GetActiveLinks(ctx, afterID, s.batchSize)
+		if err != nil {
+			s.log.Error("Can't load links", "err", err)
+			return
+		}
+
+		for i := range links {
+			if ctx.Err() != nil {
+				return
+			}
+			if !s.due(&links[i], now) {
+				continue
+			}
+			s.checkLink(ctx, &links[i])
+		}
+
+		if len(links) < s.batchSize {
+			return
+		}
+		afterID = links[len(links)-1].ID
+	}
+}
+
+// due сообщает, пора ли проверять ссылку со своим интервалом.
+// Обход идёт раз в s.interval, поэтому ссылка проверяется, если её время
+// наступит раньше середины следующего периода - иначе проверка сдвигалась бы на целый обход
+func (s *Scheduler) due(link *model.Link, now time.Time) bool {
+	if link.LastCheckedAt == nil || link.CheckInterval == nil {
+		return true
+	}
+	next := link.LastCheckedAt.Add(time.Duration(*link.CheckInterval) * time.Second)
+	return next.Before(now.Add(s.interval / 2))
+}
+
+func (s *Scheduler) checkLink(ctx context.Context, link *model.Link) {
+	source, err := s.sourceFor(ctx, link)
+	if err != nil {
+		s.log.Debug("No source for link", "link", link.Link, "err", err)
+		return
+	}
+
+	checkedAt := time.Now()
+	updates, err := source.Check(ctx, link)
+	if err != nil {
+		s.log.Warn("Link check failed", "link", link.Link, "err", err)
+		return
+	}
+
+	for i := range updates {
+		updates[i].LinkID = link.ID
+		s.log.Info("New activity",
+			"link", link.Link,
+			"type", updates[i].Type,
+			"author", updates[i].Author,
+			"url", updates[i].URL,
+		)
+	}
+
+	notifications, err := s.notifications(ctx, link, updates)
+	if err != nil {
+		// состояние не сохраняем, чтобы события нашлись при следующей проверке
+		s.log.Error("Can't prepare notifications", "link", link.Link, "err", err)
+		return
+	}
+
+	link.LastCheckedAt = &checkedAt
+	if err := s.db.UpdateLinkState(ctx, *link, updates, notifications); err != nil {
+		s.log.Error("Can't save link state", "link", link.Link, "err", err)
+	}
+}
+
+// sourceFor возвращает источник по типу ссылки.
+// Тип может быть пустым у ссылок, добавленных до появления реестра
+func (s *Scheduler) sourceFor(ctx context.Context, link *model.Link) (sources.Source, error) {
+	if link.Kind == "" {
+		return s.sources.Resolve(ctx, link.Link)
+	}
+	source, ok := s.sources.Get(link.Kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown source kind %q", link.Kind)
+	}
+	return source, nil
+}
+
+// notifications превращает события в уведомления для чатов, отслеживающих ссылку.
+// Каждый чат получает только события, прошедшие его фильтры. Уведомление адресовано
+// одному чату, чтобы outbox повторял доставку только туда, где она не удалась
+func (s *Scheduler) notifications(ctx context.Context, link *model.Link, updates []model.Update) ([]model.LinkUpdate, error) {
+	if len(updates) == 0 {
+		return nil, nil
+	}
+	chats, err := s.db.GetLinkChats(ctx, link.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]filters.Set, len(chats))
+	for i, chat := range chats {
+		set, err := filters.ParseAll(chat.Filters)
+		if err != nil {
+			// фильтры проверяются при добавлении, сюда попадают только устаревшие записи
+			s.log.Warn("Bad link filters, ignoring them", "link", link.Link, "chat", chat.ChatID, "err", err)
+		}
+		sets[i] = set
+	}
+
+	var notifications []model.LinkUpdate
+	for _, u := range updates {
+		for i, chat := range chats {
+			if !sets[i].Match(u) {
+				continue
+			}
+			notifications = append(notifications, model.LinkUpdate{
+				ID:          link.ID,
+				URL:         link.Link,
+				Description: describe(u),
+				TgChatIDs:   []int{chat.ChatID},
+			})
+		}
+	}
+	return notifications, nil
+}
+
+// describe формирует текст уведомления о событии
+func describe(u model.Update) string {
+	var b strings.Builder
+	b.WriteString(u.Type)
+	if u.Title != "" {
+		b.WriteString(": ")
+		b.WriteString(u.Title)
+	}
+	if u.Author != "" {
+		fmt.Fprintf(&b, "\nАвтор: %s", u.Author)
+	}
+	if !u.CreatedAt.IsZero() {
+		fmt.Fprintf(&b, "\nВремя: %s", u.CreatedAt.Format(time.DateTime))
+	}
+	if u.URL != "" {
+		b.WriteString("\n")
+		b.WriteString(u.URL)
+	}
+	if u.Preview != "" {
+		b.WriteString("\n\n")
+		b.WriteString(u.Preview)
+	}
+	return b.String()
+}
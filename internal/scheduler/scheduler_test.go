@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/config"
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/grigory222/scraptor/internal/sources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockRepository реализует только методы, нужные планировщику
+type mockRepository struct {
+	repository.Repository
+	mock.Mock
+}
+
+func (m *mockRepository) GetActiveLinks(_ context.Context, afterID, limit int) ([]model.Link, error) {
+	args := m.Called(afterID, limit)
+	links := args.Get(0)
+	if links != nil {
+		return links.([]model.Link), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepository) UpdateLinkState(_ context.Context, link model.Link, updates []model.Update, notifications []model.LinkUpdate) error {
+	args := m.Called(link, updates, notifications)
+	return args.Error(0)
+}
+
+func (m *mockRepository) GetLinkChats(_ context.Context, linkID int) ([]model.LinkChat, error) {
+	args := m.Called(linkID)
+	return args.Get(0).([]model.LinkChat), args.Error(1)
+}
+
+type fakeSource struct {
+	kind    string
+	host    string
+	updates []model.Update
+	err     error
+	checked []string
+}
+
+func (f *fakeSource) Kind() string {
+	return f.kind
+}
+
+func (f *fakeSource) Match(u *url.URL) bool {
+	return clients.Host(u) == f.host
+}
+
+func (f *fakeSource) Check(_ context.Context, link *model.Link) ([]model.Update, error) {
+	f.checked = append(f.checked, link.Link)
+	if f.err != nil {
+		return nil, f.err
+	}
+	link.State = []byte(`{"cursor":1}`)
+	return f.updates, nil
+}
+
+func TestCheckAll(t *testing.T) {
+	recently := time.Now().Add(-10 * time.Second)
+	longAgo := time.Now().Add(-2 * time.Minute)
+	minute := 60
+
+	tests := []struct {
+		name        string
+		batchSize   int
+		links       [][]model.Link
+		updates     []model.Update
+		err         error
+		wantChecked []string
+		wantSaved   int
+	}{
+		{
+			name:      "links are dispatched by kind",
+			batchSize: 10,
+			links: [][]model.Link{{
+				{ID: 1, Link: "https://github.com/foo/bar", Kind: "github"},
+				{ID: 2, Link: "https://example.com/feed.xml", Kind: "feed"},
+				{ID: 3, Link: "https://github.com/foo/baz", Kind: "github"},
+				{ID: 4, Link: "https://github.com/blog.atom", Kind: "unknown"},
+			}},
+			updates:     []model.Update{{Type: "issue"}},
+			wantChecked: []string{"https://github.com/foo/bar", "https://github.com/foo/baz", "https://example.com/feed.xml"},
+			wantSaved:   3,
+		},
+		{
+			name:      "links without kind are resolved by url",
+			batchSize: 10,
+			links: [][]model.Link{{
+				{ID: 1, Link: "www.github.com/foo/bar"},
+				{ID: 2, Link: "https://unknown.org/page"},
+			}},
+			wantChecked: []string{"www.github.com/foo/bar"},
+			wantSaved:   1,
+		},
+		{
+			name:      "links are loaded in batches",
+			batchSize: 2,
+			links: [][]model.Link{
+				{{ID: 1, Link: "https://github.com/a/a", Kind: "github"}, {ID: 2, Link: "https://github.com/b/b", Kind: "github"}},
+				{{ID: 5, Link: "https://github.com/c/c", Kind: "github"}},
+			},
+			wantChecked: []string{"https://github.com/a/a", "https://github.com/b/b", "https://github.com/c/c"},
+			wantSaved:   3,
+		},
+		{
+			name:      "links with own interval are checked when due",
+			batchSize: 10,
+			links: [][]model.Link{{
+				{ID: 1, Link: "https://github.com/a/a", Kind: "github", LastCheckedAt: &recently, CheckInterval: &minute},
+				{ID: 2, Link: "https://github.com/b/b", Kind: "github", LastCheckedAt: &longAgo, CheckInterval: &minute},
+				{ID: 3, Link: "https://github.com/c/c", Kind: "github", LastCheckedAt: &recently},
+			}},
+			wantChecked: []string{"https://github.com/b/b", "https://github.com/c/c"},
+			wantSaved:   2,
+		},
+		{
+			name:        "failed check does not update state",
+			batchSize:   10,
+			links:       [][]model.Link{{{ID: 1, Link: "https://github.com/foo/bar", Kind: "github"}}},
+			err:         errors.New("api is down"),
+			wantChecked: []string{"https://github.com/foo/bar"},
+			wantSaved:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepository)
+			afterID := 0
+			for _, batch := range tt.links {
+				repo.On("GetActiveLinks", afterID, tt.batchSize).Return(batch, nil).Once()
+				afterID = batch[len(batch)-1].ID
+			}
+			if last := tt.links[len(tt.links)-1]; len(last) == tt.batchSize {
+				repo.On("GetActiveLinks", afterID, tt.batchSize).Return([]model.Link{}, nil).Once()
+			}
+			repo.On("UpdateLinkState", mock.MatchedBy(func(link model.Link) bool {
+				return link.LastCheckedAt != nil && string(link.State) == `{"cursor":1}`
+			}), mock.Anything, mock.Anything).Return(nil).Maybe()
+			repo.On("GetLinkChats", mock.Anything).Return([]model.LinkChat{{ChatID: 1}}, nil).Maybe()
+
+			github := &fakeSource{kind: "github", host: "github.com", updates: tt.updates, err: tt.err}
+			feed := &fakeSource{kind: "feed"}
+			registry := sources.NewRegistry(github, feed)
+
+			s := NewScheduler(repo, registry, config.SchedulerConfig{Interval: time.Second, BatchSize: tt.batchSize}, nil)
+			s.CheckAll(context.Background())
+
+			assert.Equal(t, tt.wantChecked, append(github.checked, feed.checked...))
+			repo.AssertNumberOfCalls(t, "UpdateLinkState", tt.wantSaved)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCheckAllQueuesUpdates(t *testing.T) {
+	createdAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSource{kind: "github", host: "github.com", updates: []model.Update{
+		{Type: "issue", Title: "Bug", Author: "alice", URL: "https://github.com/foo/bar/issues/1", CreatedAt: createdAt},
+		{Type: "comment", Preview: "LGTM"},
+	}}
+	link := model.Link{ID: 7, Link: "https://github.com/foo/bar", Kind: "github"}
+
+	tests := []struct {
+		name      string
+		chats     []model.LinkChat
+		chatsErr  error
+		wantQueue []model.LinkUpdate
+		wantSaved int
+	}{
+		{
+			name:  "every chat gets its own notification",
+			chats: []model.LinkChat{{ChatID: 10}, {ChatID: 20}},
+			wantQueue: []model.LinkUpdate{
+				{
+					ID:          7,
+					URL:         "https://github.com/foo/bar",
+					Description: "issue: Bug\nАвтор: alice\nВремя: 2025-05-01 12:00:00\nhttps://github.com/foo/bar/issues/1",
+					TgChatIDs:   []int{10},
+				},
+				{
+					ID:          7,
+					URL:         "https://github.com/foo/bar",
+					Description: "issue: Bug\nАвтор: alice\nВремя: 2025-05-01 12:00:00\nhttps://github.com/foo/bar/issues/1",
+					TgChatIDs:   []int{20},
+				},
+				{ID: 7, URL: "https://github.com/foo/bar", Description: "comment\n\nLGTM", TgChatIDs: []int{10}},
+				{ID: 7, URL: "https://github.com/foo/bar", Description: "comment\n\nLGTM", TgChatIDs: []int{20}},
+			},
+			wantSaved: 1,
+		},
+		{
+			name: "chats get only updates passing their filters",
+			chats: []model.LinkChat{
+				{ChatID: 10, Filters: []string{"-user:alice"}},
+				{ChatID: 20, Filters: []string{"contains:lgtm"}},
+				{ChatID: 30, Filters: []string{"type:release"}},
+			},
+			wantQueue: []model.LinkUpdate{
+				{ID: 7, URL: "https://github.com/foo/bar", Description: "comment\n\nLGTM", TgChatIDs: []int{10}},
+				{ID: 7, URL: "https://github.com/foo/bar", Description: "comment\n\nLGTM", TgChatIDs: []int{20}},
+			},
+			wantSaved: 1,
+		},
+		{
+			name:      "no chats - nothing to queue",
+			chats:     []model.LinkChat{},
+			wantSaved: 1,
+		},
+		{
+			name:      "state is not saved if chats can't be loaded",
+			chats:     []model.LinkChat{},
+			chatsErr:  errors.New("db is down"),
+			wantSaved: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockRepository)
+			repo.On("GetActiveLinks", 0, 10).Return([]model.Link{link}, nil)
+			repo.On("GetLinkChats", 7).Return(tt.chats, tt.chatsErr)
+			repo.On("UpdateLinkState", mock.Anything, mock.Anything, tt.wantQueue).Return(nil).Maybe()
+
+			s := NewScheduler(repo, sources.NewRegistry(source), config.SchedulerConfig{Interval: time.Second, BatchSize: 10}, nil)
+			s.CheckAll(context.Background())
+
+			repo.AssertNumberOfCalls(t, "UpdateLinkState", tt.wantSaved)
+		})
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	repo := new(mockRepository)
+	repo.On("GetActiveLinks", 0, 10).Return([]model.Link{}, nil)
+
+	s := NewScheduler(repo, sources.NewRegistry(), config.SchedulerConfig{Interval: 10 * time.Millisecond, BatchSize: 10}, nil)
+	s.Start(context.Background())
+	time.Sleep(35 * time.Millisecond)
+	s.Stop()
+
+	calls := len(repo.Calls)
+	assert.GreaterOrEqual(t, calls, 2)
+
+	// после остановки обходов больше нет
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, calls, len(repo.Calls))
+}
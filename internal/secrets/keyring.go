@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// KeySize - длина ключа AES-256 в байтах
+const KeySize = 32
+
+var (
+	// ErrNoKey - ключ шифрования не задан
+	ErrNoKey = errors.New("encryption key is not configured")
+	// ErrUnknownKey - значение зашифровано ключом, которого нет в связке
+	ErrUnknownKey = errors.New("unknown encryption key")
+)
+
+// Keyring шифрует значения текущим ключом AES-GCM и расшифровывает
+// текущим или любым из прежних, что позволяет менять ключ без простоя.
+// Методы nil-связки возвращают ErrNoKey
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring создаёт связку из ключей в base64. current шифрует новые значения,
+// old нужны только для расшифровки записей, ещё не перешифрованных текущим ключом
+func NewKeyring(current string, old ...string) (*Keyring, error) {
+	k := &Keyring{aeads: make(map[string]cipher.AEAD, len(old)+1)}
+	id, err := k.add(current)
+	if err != nil {
+		return nil, err
+	}
+	k.current = id
+	for _, key := range old {
+		if _, err := k.add(key); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+func (k *Keyring) add(encoded string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("secrets: key is not valid base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return "", fmt.Errorf("secrets: key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	id := keyID(key)
+	k.aeads[id] = aead
+	return id, nil
+}
+
+// keyID - короткий отпечаток ключа, который хранится рядом с шифротекстом
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// CurrentID возвращает отпечаток текущего ключа
+func (k *Keyring) CurrentID() string {
+	if k == nil {
+		return ""
+	}
+	return k.current
+}
+
+// Encrypt шифрует plaintext текущим ключом. Случайный nonce записывается
+// в начало шифротекста. aad привязывает шифротекст к записи, которой он принадлежит:
+// расшифровать его можно только с теми же aad, поэтому секрет нельзя
+// незаметно переставить в чужую запись
+func (k *Keyring) Encrypt(plaintext, aad []byte) (id string, ciphertext []byte, err error) {
+	if k == nil {
+		return "", nil, ErrNoKey
+	}
+	aead := k.aeads[k.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.current, aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Decrypt расшифровывает значение, зашифрованное ключом с отпечатком id
+// с теми же aad, что были переданы в Encrypt
+func (k *Keyring) Decrypt(id string, ciphertext, aad []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("secrets: ciphertext is too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, aad)
+}
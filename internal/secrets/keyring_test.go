@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, KeySize))
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		old     []string
+		wantErr bool
+	}{
+		{name: "current only", current: testKey(1)},
+		{name: "with old keys", current: testKey(1), old: []string{testKey(2), testKey(3)}},
+		{name: "empty key", current: "", wantErr: true},
+		{name: "not base64", current: "not a key!", wantErr: true},
+		{name: "short key", current: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "bad old key", current: testKey(1), old: []string{"AAAA"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring(tt.current, tt.old...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, k.CurrentID(), 8)
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+
+	aad := []byte("token:1:chat:1")
+	id, first, err := k.Encrypt([]byte("ghp_secret"), aad)
+	require.NoError(t, err)
+	assert.Equal(t, k.CurrentID(), id)
+	assert.NotContains(t, string(first), "ghp_secret")
+
+	_, second, err := k.Encrypt([]byte("ghp_secret"), aad)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second, "nonce must be random")
+
+	plain, err := k.Decrypt(id, first, aad)
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_secret", string(plain))
+
+	tampered := bytes.Clone(first)
+	tampered[len(tampered)-1] ^= 1
+	_, err = k.Decrypt(id, tampered, aad)
+	assert.Error(t, err)
+
+	_, err = k.Decrypt(id, first[:4], aad)
+	assert.Error(t, err)
+
+	// шифротекст, переставленный в другую запись, не расшифровывается
+	_, err = k.Decrypt(id, first, []byte("token:2:chat:1"))
+	assert.Error(t, err)
+	_, err = k.Decrypt(id, first, nil)
+	assert.Error(t, err)
+}
+
+func TestRotation(t *testing.T) {
+	old, err := NewKeyring(testKey(1))
+	require.NoError(t, err)
+	aad := []byte("token:1:chat:1")
+	oldID, ciphertext, err := old.Encrypt([]byte("token"), aad)
+	require.NoError(t, err)
+
+	rotated, err := NewKeyring(testKey(2), testKey(1))
+	require.NoError(t, err)
+	assert.NotEqual(t, oldID, rotated.CurrentID())
+
+	plain, err := rotated.Decrypt(oldID, ciphertext, aad)
+	require.NoError(t, err)
+	assert.Equal(t, "token", string(plain))
+
+	fresh, err := NewKeyring(testKey(2))
+	require.NoError(t, err)
+	_, err = fresh.Decrypt(oldID, ciphertext, aad)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestNilKeyring(t *testing.T) {
+	var k *Keyring
+	_, _, err := k.Encrypt([]byte("token"), nil)
+	assert.ErrorIs(t, err, ErrNoKey)
+	_, err = k.Decrypt("abcd", nil, nil)
+	assert.ErrorIs(t, err, ErrNoKey)
+	assert.Empty(t, k.CurrentID())
+}
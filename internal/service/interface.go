@@ -1,11 +1,22 @@
 package service
 
-import "github.com/grigory222/scraptor/internal/model"
+import (
+	"context"
+
+	"github.com/grigory222/scraptor/internal/model"
+)
 
 type IService interface {
-	AddTgChat(id int) error
-	DeleteTgChat(id int) error
-	AddLink(chatID int, req model.LinkRequestDTO) (*model.Link, error)
-	DeleteLink(chatID int, req model.LinkDeleteRequestDTO) (*model.Link, error)
-	GetLinks(chatID int) ([]model.Link, error)
+	AddTgChat(ctx context.Context, id int) error
+	DeleteTgChat(ctx context.Context, id int) error
+	AddLink(ctx context.Context, chatID int, req model.LinkRequestDTO) (*model.Link, error)
+	DeleteLink(ctx context.Context, chatID int, req model.LinkDeleteRequestDTO) (*model.Link, error)
+	UpdateLink(ctx context.Context, chatID int, req model.LinkPatchRequestDTO) (*model.Link, error)
+	GetLinks(ctx context.Context, chatID int, tags []string) ([]model.Link, error)
+	GetLinkUpdates(ctx context.Context, chatID, linkID, limit, offset int) ([]model.HistoryEntry, error)
+	GetDeadLetters(ctx context.Context, limit, offset int) ([]model.DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, id int64) error
+	AddToken(ctx context.Context, chatID int, req model.TokenRequestDTO) (*model.Token, error)
+	GetTokens(ctx context.Context, chatID int) ([]model.Token, error)
+	DeleteToken(ctx context.Context, chatID, id int) (*model.Token, error)
 }
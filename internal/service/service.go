@@ -1,27 +1,37 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/grigory222/scraptor/internal/clients/webpage"
+	"github.com/grigory222/scraptor/internal/filters"
 	"github.com/grigory222/scraptor/internal/model"
 	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/grigory222/scraptor/internal/sources"
 )
 
 type Service struct {
-	db  repository.Repository
-	log *slog.Logger
+	db      repository.Repository
+	sources *sources.Registry
+	log     *slog.Logger
 }
 
-func NewService(db repository.Repository, log *slog.Logger) *Service {
+// NewService создаёт сервис. registry подбирает источник для новых ссылок
+func NewService(db repository.Repository, registry *sources.Registry, log *slog.Logger) *Service {
 	if log == nil {
 		log = slog.New(slog.NewTextHandler(io.Discard, nil))
 	}
-	return &Service{db: db, log: log}
+	return &Service{db: db, sources: registry, log: log}
 }
 
-func (s *Service) AddTgChat(id int) error {
-	err := s.db.AddChat(id)
+func (s *Service) AddTgChat(ctx context.Context, id int) error {
+	err := s.db.AddChat(ctx, id)
 	if err != nil && s.log != nil {
 		s.log.Error(err.Error())
 	}
@@ -29,8 +39,8 @@ func (s *Service) AddTgChat(id int) error {
 	return err
 }
 
-func (s *Service) DeleteTgChat(id int) error {
-	err := s.db.DeleteTgChat(id)
+func (s *Service) DeleteTgChat(ctx context.Context, id int) error {
+	err := s.db.DeleteTgChat(ctx, id)
 	if err != nil {
 		if s.log != nil {
 			s.log.Error(err.Error())
@@ -41,24 +51,86 @@ func (s *Service) DeleteTgChat(id int) error {
 	return nil
 }
 
-func (s *Service) AddLink(chatID int, link model.LinkRequestDTO) (*model.Link, error) {
-	linkDAO, err := s.db.AddLink(link.Link, link.Tag, link.TokenID, chatID)
+func (s *Service) AddLink(ctx context.Context, chatID int, link model.LinkRequestDTO) (*model.Link, error) {
+	if err := webpage.ValidateSelector(link.Selector); err != nil {
+		return nil, err
+	}
+	if _, err := filters.ParseAll(link.Filters); err != nil {
+		return nil, err
+	}
+	tags, err := NormalizeTags(link.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	original := strings.TrimSpace(link.Link)
+	link.Link, err = s.sources.Canonicalize(original)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.resolveSource(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	newLink := model.Link{
+		Link:     link.Link,
+		Original: original,
+		Tags:     tags,
+		Kind:     source.Kind(),
+		Selector: link.Selector,
+		Filters:  link.Filters,
+	}
+	if link.TokenID != 0 {
+		if err := s.checkTokenOwner(ctx, chatID, link.TokenID); err != nil {
+			return nil, err
+		}
+		newLink.TokenID = &link.TokenID
+	} else if r, ok := source.(sources.TokenRequirer); ok && r.RequiresToken() {
+		// без токена такая ссылка падала бы при каждой проверке
+		return nil, fmt.Errorf("%w: %s links require token_id", ErrTokenRequired, source.Kind())
+	}
+
+	linkDAO, err := s.db.AddLink(ctx, newLink, chatID)
 	if err != nil {
 		return nil, err
 	}
 	return linkDAO, nil
 }
 
-func (s *Service) GetLinks(chatID int) ([]model.Link, error) {
-	linksDAO, err := s.db.GetLinks(chatID)
+// resolveSource подбирает источник для ссылки.
+// Селектор имеет смысл только для отслеживания страницы целиком
+func (s *Service) resolveSource(ctx context.Context, link model.LinkRequestDTO) (sources.Source, error) {
+	if link.Selector != "" {
+		if _, err := s.sources.Resolve(ctx, link.Link); err != nil {
+			return nil, err
+		}
+		source, ok := s.sources.Get(webpage.Kind)
+		if !ok {
+			return nil, fmt.Errorf("%w: page tracking is disabled", sources.ErrUnsupportedLink)
+		}
+		return source, nil
+	}
+	return s.sources.Resolve(ctx, link.Link)
+}
+
+// GetLinks возвращает ссылки чата, помеченные хотя бы одним из tags (все, если tags пуст)
+func (s *Service) GetLinks(ctx context.Context, chatID int, tags []string) ([]model.Link, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	linksDAO, err := s.db.GetLinks(ctx, chatID, tags)
 	if err != nil {
 		return nil, err
 	}
 	return linksDAO, nil
 }
 
-func (s *Service) DeleteLink(chatID int, link model.LinkDeleteRequestDTO) (*model.Link, error) {
-	linkDeleted, err := s.db.DeleteLink(chatID, link.Link)
+func (s *Service) DeleteLink(ctx context.Context, chatID int, link model.LinkDeleteRequestDTO) (*model.Link, error) {
+	linkDeleted, err := s.withCanonical(link.Link, func(l string) (*model.Link, error) {
+		return s.db.DeleteLink(ctx, chatID, l)
+	})
 	if err != nil {
 		if s.log != nil {
 			s.log.Error(err.Error())
@@ -67,3 +139,120 @@ func (s *Service) DeleteLink(chatID int, link model.LinkDeleteRequestDTO) (*mode
 	}
 	return linkDeleted, nil
 }
+
+// withCanonical ищет ссылку чата по канонической форме, а если не нашёл - по исходной:
+// старые ссылки приводятся к каноническому виду при запуске, но до этого
+// или если привести не удалось, хранятся так, как их ввели
+func (s *Service) withCanonical(link string, f func(link string) (*model.Link, error)) (*model.Link, error) {
+	link = strings.TrimSpace(link)
+	canonical, err := s.sources.Canonicalize(link)
+	if err != nil || canonical == link {
+		return f(link)
+	}
+	found, err := f(canonical)
+	if errors.Is(err, repository.ErrNotFound) {
+		return f(link)
+	}
+	return found, err
+}
+
+// GetLinkUpdates возвращает историю событий ссылки, которую отслеживает чат
+func (s *Service) GetLinkUpdates(ctx context.Context, chatID, linkID, limit, offset int) ([]model.HistoryEntry, error) {
+	entries, err := s.db.GetLinkUpdates(ctx, chatID, linkID, limit, offset)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		s.log.Error("Can't load link updates", "link_id", linkID, "err", err)
+	}
+	return entries, err
+}
+
+// UpdateLink меняет статус и интервал проверки ссылки в чате
+func (s *Service) UpdateLink(ctx context.Context, chatID int, req model.LinkPatchRequestDTO) (*model.Link, error) {
+	link, err := s.withCanonical(req.Link, func(l string) (*model.Link, error) {
+		return s.db.UpdateLinkSettings(ctx, chatID, l, req.Status, req.CheckInterval)
+	})
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		s.log.Error("Can't update link settings", "link", req.Link, "err", err)
+	}
+	return link, err
+}
+
+// ================= Tokens =================
+
+// MaxTokenNameLength - наибольшая длина названия токена в символах
+const MaxTokenNameLength = 64
+
+// ErrInvalidToken - токен пустой, с некорректным названием или принадлежит другому чату
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrTokenRequired - источник ссылки работает только с токеном доступа
+var ErrTokenRequired = errors.New("token required")
+
+// AddToken сохраняет токен доступа чата в зашифрованном виде
+func (s *Service) AddToken(ctx context.Context, chatID int, req model.TokenRequestDTO) (*model.Token, error) {
+	token := strings.TrimSpace(req.Token)
+	name := strings.TrimSpace(req.Name)
+	if token == "" {
+		return nil, fmt.Errorf("%w: token is empty", ErrInvalidToken)
+	}
+	if utf8.RuneCountInString(name) > MaxTokenNameLength {
+		return nil, fmt.Errorf("%w: name is longer than %d characters", ErrInvalidToken, MaxTokenNameLength)
+	}
+
+	t, err := s.db.AddToken(ctx, chatID, name, token)
+	if err != nil {
+		s.log.Error("Can't save token", "chat_id", chatID, "err", err)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *Service) GetTokens(ctx context.Context, chatID int) ([]model.Token, error) {
+	tokens, err := s.db.GetTokens(ctx, chatID)
+	if err != nil {
+		s.log.Error("Can't load tokens", "chat_id", chatID, "err", err)
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *Service) DeleteToken(ctx context.Context, chatID, id int) (*model.Token, error) {
+	t, err := s.db.DeleteToken(ctx, chatID, id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) && !errors.Is(err, repository.ErrTokenInUse) {
+		s.log.Error("Can't delete token", "chat_id", chatID, "id", id, "err", err)
+	}
+	return t, err
+}
+
+// checkTokenOwner проверяет, что чат ссылается только на свой токен
+func (s *Service) checkTokenOwner(ctx context.Context, chatID, tokenID int) error {
+	tokens, err := s.db.GetTokens(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.ID == tokenID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no token with id %d", ErrInvalidToken, tokenID)
+}
+
+// ================= Admin =================
+
+func (s *Service) GetDeadLetters(ctx context.Context, limit, offset int) ([]model.DeadLetter, error) {
+	letters, err := s.db.GetDeadLetters(ctx, limit, offset)
+	if err != nil {
+		s.log.Error("Can't load dead letters", "err", err)
+		return nil, err
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetter возвращает уведомление в outbox для повторной отправки
+func (s *Service) ReplayDeadLetter(ctx context.Context, id int64) error {
+	err := s.db.ReplayDeadLetter(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		s.log.Error("Can't replay dead letter", "id", id, "err", err)
+	}
+	return err
+}
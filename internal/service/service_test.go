@@ -1,11 +1,20 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/grigory222/scraptor/internal/filters"
 	"github.com/grigory222/scraptor/internal/model"
+	"github.com/grigory222/scraptor/internal/repository"
+	"github.com/grigory222/scraptor/internal/secrets"
+	"github.com/grigory222/scraptor/internal/sources"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -15,22 +24,47 @@ var (
 	zero = 0
 )
 
+// fakeSource узнаёт ссылки по хосту
+type fakeSource struct {
+	kind  string
+	hosts []string
+}
+
+func (f fakeSource) Kind() string {
+	return f.kind
+}
+
+func (f fakeSource) Check(context.Context, *model.Link) ([]model.Update, error) {
+	return nil, nil
+}
+
+func (f fakeSource) Match(u *url.URL) bool {
+	return slices.Contains(f.hosts, u.Host)
+}
+
+func newTestRegistry() *sources.Registry {
+	return sources.NewRegistry(
+		fakeSource{kind: "test", hosts: []string{"example.com", "error.com"}},
+		fakeSource{kind: "html"},
+	)
+}
+
 type MockRepository struct {
 	mock.Mock
 }
 
-func (m *MockRepository) AddChat(id int) error {
+func (m *MockRepository) AddChat(_ context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *MockRepository) DeleteTgChat(id int) error {
+func (m *MockRepository) DeleteTgChat(_ context.Context, id int) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *MockRepository) AddLink(link, tag string, tokenID, chatID int) (*model.Link, error) {
-	args := m.Called(link, tag, tokenID, chatID)
+func (m *MockRepository) AddLink(_ context.Context, link model.Link, chatID int) (*model.Link, error) {
+	args := m.Called(link, chatID)
 	linkk := args.Get(0)
 	if linkk != nil {
 		return linkk.(*model.Link), args.Error(1)
@@ -38,8 +72,8 @@ func (m *MockRepository) AddLink(link, tag string, tokenID, chatID int) (*model.
 	return nil, args.Error(1)
 }
 
-func (m *MockRepository) GetLinks(chatID int) ([]model.Link, error) {
-	args := m.Called(chatID)
+func (m *MockRepository) GetLinks(_ context.Context, chatID int, tags []string) ([]model.Link, error) {
+	args := m.Called(chatID, tags)
 	links := args.Get(0)
 	if links != nil {
 		return links.([]model.Link), args.Error(1)
@@ -47,7 +81,7 @@ func (m *MockRepository) GetLinks(chatID int) ([]model.Link, error) {
 	return nil, args.Error(1)
 }
 
-func (m *MockRepository) DeleteLink(chatID int, link string) (*model.Link, error) {
+func (m *MockRepository) DeleteLink(_ context.Context, chatID int, link string) (*model.Link, error) {
 	args := m.Called(chatID, link)
 	linkk := args.Get(0)
 	if linkk != nil {
@@ -56,6 +90,88 @@ func (m *MockRepository) DeleteLink(chatID int, link string) (*model.Link, error
 	return nil, args.Error(1)
 }
 
+func (m *MockRepository) UpdateLinkSettings(_ context.Context, chatID int, link string, status *string, checkInterval *int) (*model.Link, error) {
+	args := m.Called(chatID, link, status, checkInterval)
+	if l := args.Get(0); l != nil {
+		return l.(*model.Link), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetActiveLinks(_ context.Context, afterID, limit int) ([]model.Link, error) {
+	args := m.Called(afterID, limit)
+	links := args.Get(0)
+	if links != nil {
+		return links.([]model.Link), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) UpdateLinkState(_ context.Context, link model.Link, updates []model.Update, notifications []model.LinkUpdate) error {
+	args := m.Called(link, updates, notifications)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ClaimOutbox(_ context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error) {
+	args := m.Called(limit, lease)
+	return args.Get(0).([]model.OutboxMessage), args.Error(1)
+}
+
+func (m *MockRepository) MarkOutboxSent(_ context.Context, id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) MarkOutboxFailed(_ context.Context, id int64, nextAttempt time.Time, reason string) error {
+	args := m.Called(id, nextAttempt, reason)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetLinkChats(_ context.Context, linkID int) ([]model.LinkChat, error) {
+	args := m.Called(linkID)
+	return args.Get(0).([]model.LinkChat), args.Error(1)
+}
+
+func (m *MockRepository) MarkOutboxDead(_ context.Context, id int64, reason string) error {
+	args := m.Called(id, reason)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetLinkUpdates(_ context.Context, chatID, linkID, limit, offset int) ([]model.HistoryEntry, error) {
+	args := m.Called(chatID, linkID, limit, offset)
+	return args.Get(0).([]model.HistoryEntry), args.Error(1)
+}
+
+func (m *MockRepository) GetDeadLetters(_ context.Context, limit, offset int) ([]model.DeadLetter, error) {
+	args := m.Called(limit, offset)
+	return args.Get(0).([]model.DeadLetter), args.Error(1)
+}
+
+func (m *MockRepository) ReplayDeadLetter(_ context.Context, id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) AddToken(_ context.Context, chatID int, name, token string) (*model.Token, error) {
+	args := m.Called(chatID, name, token)
+	return args.Get(0).(*model.Token), args.Error(1)
+}
+
+func (m *MockRepository) GetTokens(_ context.Context, chatID int) ([]model.Token, error) {
+	args := m.Called(chatID)
+	return args.Get(0).([]model.Token), args.Error(1)
+}
+
+func (m *MockRepository) DeleteToken(_ context.Context, chatID, id int) (*model.Token, error) {
+	args := m.Called(chatID, id)
+	return args.Get(0).(*model.Token), args.Error(1)
+}
+
+func (m *MockRepository) GetToken(_ context.Context, id int) (string, error) {
+	args := m.Called(id)
+	return args.String(0), args.Error(1)
+}
+
 func TestAddTgChat(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -86,8 +202,8 @@ func TestAddTgChat(t *testing.T) {
 			repo := new(MockRepository)
 			tt.mockSetup(repo)
 
-			s := NewService(repo, nil)
-			err := s.AddTgChat(tt.chatID)
+			s := NewService(repo, newTestRegistry(), nil)
+			err := s.AddTgChat(context.Background(), tt.chatID)
 
 			assert.Equal(t, tt.expectedErr, err)
 			repo.AssertExpectations(t)
@@ -125,8 +241,8 @@ func TestDeleteTgChat(t *testing.T) {
 			repo := new(MockRepository)
 			tt.mockSetup(repo)
 
-			s := NewService(repo, nil)
-			err := s.DeleteTgChat(tt.chatID)
+			s := NewService(repo, newTestRegistry(), nil)
+			err := s.DeleteTgChat(context.Background(), tt.chatID)
 
 			assert.Equal(t, tt.expectedErr, err)
 			repo.AssertExpectations(t)
@@ -146,25 +262,48 @@ func TestAddLink(t *testing.T) {
 		{
 			name:   "success",
 			chatID: 123,
-			link:   model.LinkRequestDTO{Link: "https://example.com", Tag: "test", TokenID: 1},
+			link:   model.LinkRequestDTO{Link: "https://example.com", Tags: []string{"test"}, TokenID: 1},
 			mockSetup: func(m *MockRepository) {
-				m.On("AddLink", "https://example.com", "test", 1, 123).
-					Return(&model.Link{ID: 1, Link: "https://example.com", Tag: "test", TokenID: &one}, nil)
+				m.On("GetTokens", 123).Return([]model.Token{{ID: 1, ChatID: 123}}, nil)
+				m.On("AddLink", model.Link{Link: "https://example.com", Original: "https://example.com", Tags: []string{"test"}, TokenID: &one, Kind: "test"}, 123).
+					Return(&model.Link{ID: 1, Link: "https://example.com", Tags: []string{"test"}, TokenID: &one, Kind: "test"}, nil)
 			},
-			expected:    &model.Link{ID: 1, Link: "https://example.com", Tag: "test", TokenID: &one},
+			expected:    &model.Link{ID: 1, Link: "https://example.com", Tags: []string{"test"}, TokenID: &one, Kind: "test"},
 			expectedErr: nil,
 		},
 		{
 			name:   "repository error",
 			chatID: 123,
-			link:   model.LinkRequestDTO{Link: "https://error.com", Tag: "test", TokenID: 1},
+			link:   model.LinkRequestDTO{Link: "https://error.com", Tags: []string{"test"}, TokenID: 1},
 			mockSetup: func(m *MockRepository) {
-				m.On("AddLink", "https://error.com", "test", 1, 123).
+				m.On("GetTokens", 123).Return([]model.Token{{ID: 1, ChatID: 123}}, nil)
+				m.On("AddLink", model.Link{Link: "https://error.com", Original: "https://error.com", Tags: []string{"test"}, TokenID: &one, Kind: "test"}, 123).
 					Return(nil, errors.New("db error"))
 			},
 			expected:    nil,
 			expectedErr: errors.New("db error"),
 		},
+		{
+			name:   "canonical form is stored",
+			chatID: 123,
+			link:   model.LinkRequestDTO{Link: " Example.com/foo?utm_source=tg "},
+			mockSetup: func(m *MockRepository) {
+				m.On("AddLink", model.Link{Link: "https://example.com/foo", Original: "Example.com/foo?utm_source=tg", Kind: "test"}, 123).
+					Return(&model.Link{ID: 2, Link: "https://example.com/foo", Original: "Example.com/foo?utm_source=tg", Kind: "test"}, nil)
+			},
+			expected:    &model.Link{ID: 2, Link: "https://example.com/foo", Original: "Example.com/foo?utm_source=tg", Kind: "test"},
+			expectedErr: nil,
+		},
+		{
+			name:   "token of another chat",
+			chatID: 123,
+			link:   model.LinkRequestDTO{Link: "https://example.com", TokenID: 2},
+			mockSetup: func(m *MockRepository) {
+				m.On("GetTokens", 123).Return([]model.Token{{ID: 1, ChatID: 123}}, nil)
+			},
+			expected:    nil,
+			expectedErr: fmt.Errorf("%w: no token with id 2", ErrInvalidToken),
+		},
 	}
 
 	for _, tt := range tests {
@@ -172,8 +311,8 @@ func TestAddLink(t *testing.T) {
 			repo := new(MockRepository)
 			tt.mockSetup(repo)
 
-			s := NewService(repo, nil)
-			result, err := s.AddLink(tt.chatID, tt.link)
+			s := NewService(repo, newTestRegistry(), nil)
+			result, err := s.AddLink(context.Background(), tt.chatID, tt.link)
 
 			if tt.expected == nil {
 				assert.Nil(t, result)
@@ -186,10 +325,122 @@ func TestAddLink(t *testing.T) {
 	}
 }
 
+func TestAddLinkUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+	}{
+		{name: "unknown host", link: "https://unknown.org/page"},
+		{name: "not a url", link: "ftp://example.com/file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+
+			s := NewService(repo, newTestRegistry(), nil)
+			_, err := s.AddLink(context.Background(), 123, model.LinkRequestDTO{Link: tt.link})
+
+			assert.ErrorIs(t, err, sources.ErrUnsupportedLink)
+			repo.AssertNotCalled(t, "AddLink", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// tokenSource не работает без токена, как VK
+type tokenSource struct {
+	fakeSource
+}
+
+func (tokenSource) RequiresToken() bool {
+	return true
+}
+
+func TestAddLinkRequiresToken(t *testing.T) {
+	registry := sources.NewRegistry(tokenSource{fakeSource{kind: "vk", hosts: []string{"vk.com"}}})
+
+	t.Run("without token", func(t *testing.T) {
+		repo := new(MockRepository)
+
+		s := NewService(repo, registry, nil)
+		_, err := s.AddLink(context.Background(), 123, model.LinkRequestDTO{Link: "https://vk.com/im"})
+
+		assert.ErrorIs(t, err, ErrTokenRequired)
+		repo.AssertNotCalled(t, "AddLink", mock.Anything, mock.Anything)
+	})
+
+	t.Run("with token", func(t *testing.T) {
+		repo := new(MockRepository)
+		link := model.Link{Link: "https://vk.com/im", Original: "https://vk.com/im", Kind: "vk", TokenID: &one}
+		repo.On("GetTokens", 123).Return([]model.Token{{ID: 1, ChatID: 123}}, nil)
+		repo.On("AddLink", link, 123).Return(&link, nil)
+
+		s := NewService(repo, registry, nil)
+		_, err := s.AddLink(context.Background(), 123, model.LinkRequestDTO{Link: "https://vk.com/im", TokenID: 1})
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+}
+
+func TestAddLinkSelector(t *testing.T) {
+	t.Run("selector marks link as html page", func(t *testing.T) {
+		repo := new(MockRepository)
+		link := model.Link{Link: "https://example.com/news", Original: "https://example.com/news", Kind: "html", Selector: "#news > li"}
+		repo.On("AddLink", link, 123).Return(&link, nil)
+
+		s := NewService(repo, newTestRegistry(), nil)
+		result, err := s.AddLink(context.Background(), 123, model.LinkRequestDTO{Link: "https://example.com/news", Selector: "#news > li"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &link, result)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("invalid selector", func(t *testing.T) {
+		repo := new(MockRepository)
+
+		s := NewService(repo, newTestRegistry(), nil)
+		_, err := s.AddLink(context.Background(), 123, model.LinkRequestDTO{Link: "https://example.com/news", Selector: "div >"})
+
+		assert.ErrorContains(t, err, "invalid selector")
+		repo.AssertNotCalled(t, "AddLink", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAddLinkFilters(t *testing.T) {
+	t.Run("filters are stored with link", func(t *testing.T) {
+		repo := new(MockRepository)
+		link := model.Link{Link: "https://example.com/foo", Original: "https://example.com/foo", Kind: "test", Filters: []string{"-user:bot", "contains:release"}}
+		repo.On("AddLink", link, 123).Return(&link, nil)
+
+		s := NewService(repo, newTestRegistry(), nil)
+		result, err := s.AddLink(context.Background(), 123, model.LinkRequestDTO{
+			Link:    "https://example.com/foo",
+			Filters: []string{"-user:bot", "contains:release"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &link, result)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		repo := new(MockRepository)
+
+		s := NewService(repo, newTestRegistry(), nil)
+		_, err := s.AddLink(context.Background(), 123, model.LinkRequestDTO{Link: "https://example.com/foo", Filters: []string{"author:bob"}})
+
+		assert.ErrorIs(t, err, filters.ErrInvalidFilter)
+		repo.AssertNotCalled(t, "AddLink", mock.Anything, mock.Anything)
+	})
+}
+
 func TestGetLinks(t *testing.T) {
 	tests := []struct {
 		name        string
 		chatID      int
+		tags        []string
 		mockSetup   func(*MockRepository)
 		expected    []model.Link
 		expectedErr error
@@ -198,15 +449,15 @@ func TestGetLinks(t *testing.T) {
 			name:   "success",
 			chatID: 123,
 			mockSetup: func(m *MockRepository) {
-				m.On("GetLinks", 123).
+				m.On("GetLinks", 123, []string(nil)).
 					Return([]model.Link{
-						{ID: 1, Link: "https://example.com", Tag: "test1", TokenID: &one},
-						{ID: 2, Link: "https://example.org", Tag: "test2", TokenID: &zero},
+						{ID: 1, Link: "https://example.com", Tags: []string{"test1"}, TokenID: &one},
+						{ID: 2, Link: "https://example.org", Tags: []string{"test2"}, TokenID: &zero},
 					}, nil)
 			},
 			expected: []model.Link{
-				{ID: 1, Link: "https://example.com", Tag: "test1", TokenID: &one},
-				{ID: 2, Link: "https://example.org", Tag: "test2", TokenID: &zero},
+				{ID: 1, Link: "https://example.com", Tags: []string{"test1"}, TokenID: &one},
+				{ID: 2, Link: "https://example.org", Tags: []string{"test2"}, TokenID: &zero},
 			},
 			expectedErr: nil,
 		},
@@ -214,11 +465,29 @@ func TestGetLinks(t *testing.T) {
 			name:   "empty result",
 			chatID: 456,
 			mockSetup: func(m *MockRepository) {
-				m.On("GetLinks", 456).Return([]model.Link{}, nil)
+				m.On("GetLinks", 456, []string(nil)).Return([]model.Link{}, nil)
+			},
+			expected:    []model.Link{},
+			expectedErr: nil,
+		},
+		{
+			name:   "tags are normalized",
+			chatID: 123,
+			tags:   []string{" Work", "work", "HOME"},
+			mockSetup: func(m *MockRepository) {
+				m.On("GetLinks", 123, []string{"home", "work"}).Return([]model.Link{}, nil)
 			},
 			expected:    []model.Link{},
 			expectedErr: nil,
 		},
+		{
+			name:        "empty tag",
+			chatID:      123,
+			tags:        []string{"work", " "},
+			mockSetup:   func(m *MockRepository) {},
+			expected:    nil,
+			expectedErr: fmt.Errorf("%w: tag is empty", ErrInvalidTag),
+		},
 	}
 
 	for _, tt := range tests {
@@ -226,8 +495,8 @@ func TestGetLinks(t *testing.T) {
 			repo := new(MockRepository)
 			tt.mockSetup(repo)
 
-			s := NewService(repo, nil)
-			result, err := s.GetLinks(tt.chatID)
+			s := NewService(repo, newTestRegistry(), nil)
+			result, err := s.GetLinks(context.Background(), tt.chatID, tt.tags)
 
 			assert.Equal(t, tt.expected, result)
 			assert.Equal(t, tt.expectedErr, err)
@@ -251,9 +520,33 @@ func TestDeleteLink(t *testing.T) {
 			link:   model.LinkDeleteRequestDTO{Link: "https://example.com"},
 			mockSetup: func(m *MockRepository) {
 				m.On("DeleteLink", 123, "https://example.com").
-					Return(&model.Link{ID: 1, Link: "https://example.com", Tag: "test", TokenID: &one}, nil)
+					Return(&model.Link{ID: 1, Link: "https://example.com", Tags: []string{"test"}, TokenID: &one}, nil)
+			},
+			expected:    &model.Link{ID: 1, Link: "https://example.com", Tags: []string{"test"}, TokenID: &one},
+			expectedErr: nil,
+		},
+		{
+			name:   "canonical form",
+			chatID: 123,
+			link:   model.LinkDeleteRequestDTO{Link: "HTTPS://Example.com/?utm_source=tg"},
+			mockSetup: func(m *MockRepository) {
+				m.On("DeleteLink", 123, "https://example.com").
+					Return(&model.Link{ID: 1, Link: "https://example.com"}, nil)
+			},
+			expected:    &model.Link{ID: 1, Link: "https://example.com"},
+			expectedErr: nil,
+		},
+		{
+			name:   "link added before canonicalization",
+			chatID: 123,
+			link:   model.LinkDeleteRequestDTO{Link: "https://example.com/foo?utm_source=tg"},
+			mockSetup: func(m *MockRepository) {
+				m.On("DeleteLink", 123, "https://example.com/foo").
+					Return(nil, repository.ErrNotFound)
+				m.On("DeleteLink", 123, "https://example.com/foo?utm_source=tg").
+					Return(&model.Link{ID: 3, Link: "https://example.com/foo?utm_source=tg"}, nil)
 			},
-			expected:    &model.Link{ID: 1, Link: "https://example.com", Tag: "test", TokenID: &one},
+			expected:    &model.Link{ID: 3, Link: "https://example.com/foo?utm_source=tg"},
 			expectedErr: nil,
 		},
 		{
@@ -274,8 +567,8 @@ func TestDeleteLink(t *testing.T) {
 			repo := new(MockRepository)
 			tt.mockSetup(repo)
 
-			s := NewService(repo, nil)
-			result, err := s.DeleteLink(tt.chatID, tt.link)
+			s := NewService(repo, newTestRegistry(), nil)
+			result, err := s.DeleteLink(context.Background(), tt.chatID, tt.link)
 
 			assert.Equal(t, tt.expected, result)
 			assert.Equal(t, tt.expectedErr, err)
@@ -283,3 +576,58 @@ func TestDeleteLink(t *testing.T) {
 		})
 	}
 }
+
+func TestAddToken(t *testing.T) {
+	created := &model.Token{ID: 3, ChatID: 123, Name: "github"}
+
+	tests := []struct {
+		name      string
+		req       model.TokenRequestDTO
+		mockSetup func(*MockRepository)
+		want      *model.Token
+		wantErr   error
+	}{
+		{
+			name: "success",
+			req:  model.TokenRequestDTO{Name: " github ", Token: " ghp_secret\n"},
+			mockSetup: func(m *MockRepository) {
+				m.On("AddToken", 123, "github", "ghp_secret").Return(created, nil)
+			},
+			want: created,
+		},
+		{
+			name:      "empty token",
+			req:       model.TokenRequestDTO{Name: "github", Token: "  "},
+			mockSetup: func(m *MockRepository) {},
+			wantErr:   ErrInvalidToken,
+		},
+		{
+			name:      "long name",
+			req:       model.TokenRequestDTO{Name: strings.Repeat("я", MaxTokenNameLength+1), Token: "ghp_secret"},
+			mockSetup: func(m *MockRepository) {},
+			wantErr:   ErrInvalidToken,
+		},
+		{
+			name: "repository error",
+			req:  model.TokenRequestDTO{Token: "ghp_secret"},
+			mockSetup: func(m *MockRepository) {
+				m.On("AddToken", 123, "", "ghp_secret").Return((*model.Token)(nil), secrets.ErrNoKey)
+			},
+			wantErr: secrets.ErrNoKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRepository)
+			tt.mockSetup(repo)
+
+			s := NewService(repo, newTestRegistry(), nil)
+			got, err := s.AddToken(context.Background(), 123, tt.req)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+			repo.AssertExpectations(t)
+		})
+	}
+}
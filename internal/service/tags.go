@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTagLength - наибольшая длина тега в символах
+const MaxTagLength = 64
+
+// ErrInvalidTag - тег пустой, слишком длинный или содержит запятую
+var ErrInvalidTag = errors.New("invalid tag")
+
+// NormalizeTags приводит теги к нижнему регистру, убирает пробелы по краям и повторы.
+// Запятая запрещена: в GET /links?tag=a,b она разделяет теги
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t := strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case t == "":
+			return nil, fmt.Errorf("%w: tag is empty", ErrInvalidTag)
+		case utf8.RuneCountInString(t) > MaxTagLength:
+			return nil, fmt.Errorf("%w %q: longer than %d characters", ErrInvalidTag, tag, MaxTagLength)
+		case strings.Contains(t, ","):
+			return nil, fmt.Errorf("%w %q: must not contain commas", ErrInvalidTag, tag)
+		}
+		normalized = append(normalized, t)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
@@ -0,0 +1,90 @@
+package sources
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/grigory222/scraptor/internal/clients"
+)
+
+// Canonicalizer - источник, который приводит свои ссылки к единому виду:
+// убирает части пути и параметры, не влияющие на то, что отслеживается
+type Canonicalizer interface {
+	Canonical(u *url.URL) string
+}
+
+// trackingParams - параметры запроса, которые добавляют рекламные и аналитические системы
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"yclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"ref_src": true,
+	"_ga":     true,
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	return trackingParams[name] || strings.HasPrefix(name, "utm_")
+}
+
+// Canonicalize приводит ссылку к каноническому виду, чтобы одну и ту же страницу,
+// записанную по-разному, отслеживать как одну ссылку. Общие правила: https:// по умолчанию,
+// схема и хост в нижнем регистре, без порта по умолчанию, фрагмента и параметров
+// отслеживания, остальные параметры отсортированы. Путь не меняется: для произвольного
+// сайта /feed/ и /feed, как и %2F и /, могут быть разными ресурсами. Его нормализуют
+// правила источника, если ссылку узнал ровно один URLMatcher и он умеет Canonicalizer
+func (r *Registry) Canonicalize(link string) (string, error) {
+	u, err := clients.ParseURL(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("%w: %s is not a valid http(s) url", ErrUnsupportedLink, link)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%w: %s is not a valid http(s) url", ErrUnsupportedLink, link)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	u.Host = host
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	}
+
+	u.Fragment, u.RawFragment = "", ""
+	if u.Path == "/" {
+		// пустой путь и корень - один и тот же ресурс
+		u.Path, u.RawPath = "", ""
+	}
+
+	query := u.Query()
+	for name := range query {
+		if isTrackingParam(name) {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	var matched []Source
+	for _, src := range r.matchers {
+		if src.(URLMatcher).Match(u) {
+			matched = append(matched, src)
+		}
+	}
+	if len(matched) == 1 {
+		if c, ok := matched[0].(Canonicalizer); ok {
+			return c.Canonical(u), nil
+		}
+	}
+	return u.String(), nil
+}
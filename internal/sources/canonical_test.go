@@ -0,0 +1,69 @@
+package sources
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lowerSource приводит путь своих ссылок к нижнему регистру
+type lowerSource struct {
+	hostSource
+}
+
+func (s lowerSource) Canonical(u *url.URL) string {
+	return "https://" + u.Host + strings.ToLower(strings.TrimRight(u.Path, "/"))
+}
+
+func TestCanonicalize(t *testing.T) {
+	r := NewRegistry(
+		lowerSource{hostSource{fakeSource{"github"}, "github.com"}},
+		hostSource{fakeSource{"plain"}, "plain.org"},
+		probeSource{fakeSource{"html"}, ""},
+	)
+
+	tests := []struct {
+		name        string
+		link        string
+		want        string
+		unsupported bool
+	}{
+		{name: "already canonical", link: "https://example.com/page", want: "https://example.com/page"},
+		{name: "scheme added", link: "example.com/page", want: "https://example.com/page"},
+		{name: "scheme and host case", link: "HTTPS://Example.COM/Page", want: "https://example.com/Page"},
+		{name: "http is kept", link: "http://example.com/page", want: "http://example.com/page"},
+		{name: "default port", link: "https://example.com:443/page", want: "https://example.com/page"},
+		{name: "other port is kept", link: "http://example.com:8080/page", want: "http://example.com:8080/page"},
+		{name: "trailing slash is kept", link: "https://example.com/feed/", want: "https://example.com/feed/"},
+		{name: "escaped slash is kept", link: "https://example.com/a%2Fb", want: "https://example.com/a%2Fb"},
+		{name: "root", link: "https://example.com/", want: "https://example.com"},
+		{name: "fragment", link: "https://example.com/page#comments", want: "https://example.com/page"},
+		{
+			name: "tracking params",
+			link: "https://example.com/page?utm_source=tg&UTM_Medium=x&fbclid=1&id=5",
+			want: "https://example.com/page?id=5",
+		},
+		{name: "params sorted", link: "https://example.com/feed?b=2&a=1", want: "https://example.com/feed?a=1&b=2"},
+		{name: "only tracking params", link: "https://example.com/page?utm_source=tg", want: "https://example.com/page"},
+		{name: "spaces around", link: "  https://example.com/page  ", want: "https://example.com/page"},
+		{name: "source rules", link: "http://GitHub.com/Foo/Bar/?tab=readme", want: "https://github.com/foo/bar"},
+		{name: "source without rules", link: "https://plain.org/Foo/", want: "https://plain.org/Foo/"},
+		{name: "not http", link: "ftp://example.com/file", unsupported: true},
+		{name: "no host", link: "https://", unsupported: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Canonicalize(tt.link)
+			if tt.unsupported {
+				assert.ErrorIs(t, err, ErrUnsupportedLink)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
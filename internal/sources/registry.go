@@ -0,0 +1,121 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/grigory222/scraptor/internal/clients"
+	"github.com/grigory222/scraptor/internal/model"
+)
+
+// ErrUnsupportedLink - ни один источник не умеет отслеживать ссылку
+var ErrUnsupportedLink = errors.New("no source can handle this link")
+
+// ErrAmbiguousLink - ссылку узнали сразу несколько источников
+var ErrAmbiguousLink = errors.New("ambiguous link")
+
+// Source - источник обновлений (GitHub, StackOverflow, лента, страница...)
+type Source interface {
+	// Kind - тип источника, сохраняется у ссылки
+	Kind() string
+	// Check возвращает новые события по ссылке.
+	// Источник может изменить link.State - оно будет сохранено после проверки
+	Check(ctx context.Context, link *model.Link) ([]model.Update, error)
+}
+
+// URLMatcher - источник, который узнаёт свои ссылки по виду URL
+type URLMatcher interface {
+	Match(u *url.URL) bool
+}
+
+// Prober - источник, который узнаёт свои ссылки по ответу сервера.
+// Такие источники проверяются, только если не подошёл ни один URLMatcher
+type Prober interface {
+	Probe(ctx context.Context, link string) bool
+}
+
+// TokenRequirer - источник, который не может проверять ссылки без токена доступа
+type TokenRequirer interface {
+	RequiresToken() bool
+}
+
+// Registry подбирает источник для ссылки
+type Registry struct {
+	matchers []Source
+	probers  []Source
+	byKind   map[string]Source
+
+	checkHost func(ctx context.Context, host string) error
+}
+
+// NewRegistry создаёт реестр. Источники-Prober'ы опрашиваются в порядке передачи
+func NewRegistry(sources ...Source) *Registry {
+	r := &Registry{byKind: make(map[string]Source, len(sources))}
+	for _, src := range sources {
+		if _, ok := r.byKind[src.Kind()]; ok {
+			panic(fmt.Sprintf("sources: duplicate kind %q", src.Kind()))
+		}
+		r.byKind[src.Kind()] = src
+
+		if _, ok := src.(URLMatcher); ok {
+			r.matchers = append(r.matchers, src)
+		}
+		if _, ok := src.(Prober); ok {
+			r.probers = append(r.probers, src)
+		}
+	}
+	return r
+}
+
+// SetHostCheck задаёт проверку хоста ссылки перед тем, как её опросят Prober'ы.
+// Ссылка, не прошедшая проверку, считается неподдерживаемой
+func (r *Registry) SetHostCheck(check func(ctx context.Context, host string) error) {
+	r.checkHost = check
+}
+
+// Get возвращает источник по типу, сохранённому у ссылки
+func (r *Registry) Get(kind string) (Source, bool) {
+	src, ok := r.byKind[kind]
+	return src, ok
+}
+
+// Resolve подбирает ровно один источник для ссылки
+func (r *Registry) Resolve(ctx context.Context, link string) (Source, error) {
+	u, err := clients.ParseURL(link)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%w: %s is not a valid http(s) url", ErrUnsupportedLink, link)
+	}
+
+	var matched []Source
+	for _, src := range r.matchers {
+		if src.(URLMatcher).Match(u) {
+			matched = append(matched, src)
+		}
+	}
+	switch len(matched) {
+	case 0:
+	case 1:
+		return matched[0], nil
+	default:
+		kinds := make([]string, len(matched))
+		for i, src := range matched {
+			kinds[i] = src.Kind()
+		}
+		return nil, fmt.Errorf("sources: %w %s matches %s", ErrAmbiguousLink, link, strings.Join(kinds, ", "))
+	}
+
+	if r.checkHost != nil && len(r.probers) > 0 {
+		if err := r.checkHost(ctx, u.Hostname()); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrUnsupportedLink, link, err)
+		}
+	}
+	for _, src := range r.probers {
+		if src.(Prober).Probe(ctx, u.String()) {
+			return src, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedLink, link)
+}
@@ -0,0 +1,134 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/grigory222/scraptor/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	kind string
+}
+
+func (f fakeSource) Kind() string {
+	return f.kind
+}
+
+func (f fakeSource) Check(context.Context, *model.Link) ([]model.Update, error) {
+	return nil, nil
+}
+
+// hostSource узнаёт ссылки по хосту
+type hostSource struct {
+	fakeSource
+	host string
+}
+
+func (s hostSource) Match(u *url.URL) bool {
+	return u.Host == s.host
+}
+
+// probeSource узнаёт ссылки по суффиксу пути
+type probeSource struct {
+	fakeSource
+	suffix string
+}
+
+func (s probeSource) Probe(_ context.Context, link string) bool {
+	return strings.HasSuffix(link, s.suffix)
+}
+
+func TestResolve(t *testing.T) {
+	r := NewRegistry(
+		hostSource{fakeSource{"github"}, "github.com"},
+		hostSource{fakeSource{"mirror"}, "mirror.org"},
+		hostSource{fakeSource{"mirror2"}, "mirror.org"},
+		probeSource{fakeSource{"feed"}, ".xml"},
+		probeSource{fakeSource{"html"}, ""},
+	)
+
+	tests := []struct {
+		name        string
+		link        string
+		wantKind    string
+		unsupported bool
+		wantErr     bool
+	}{
+		{name: "matched by url", link: "https://github.com/foo/bar", wantKind: "github"},
+		{name: "scheme is optional", link: "github.com/foo/bar", wantKind: "github"},
+		{name: "url matchers go before probers", link: "https://github.com/foo.xml", wantKind: "github"},
+		{name: "probers in order", link: "https://example.com/feed.xml", wantKind: "feed"},
+		{name: "last prober", link: "https://example.com/page", wantKind: "html"},
+		{name: "ambiguous", link: "https://mirror.org/x", wantErr: true},
+		{name: "not http", link: "ftp://example.com/file", unsupported: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := r.Resolve(context.Background(), tt.link)
+			switch {
+			case tt.unsupported:
+				assert.ErrorIs(t, err, ErrUnsupportedLink)
+			case tt.wantErr:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, ErrUnsupportedLink)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantKind, src.Kind())
+			}
+		})
+	}
+}
+
+func TestResolveUnsupported(t *testing.T) {
+	r := NewRegistry(hostSource{fakeSource{"github"}, "github.com"})
+
+	_, err := r.Resolve(context.Background(), "https://example.com")
+	assert.ErrorIs(t, err, ErrUnsupportedLink)
+}
+
+func TestResolveHostCheck(t *testing.T) {
+	r := NewRegistry(
+		hostSource{fakeSource{"github"}, "github.com"},
+		probeSource{fakeSource{"html"}, ""},
+	)
+	var checked []string
+	r.SetHostCheck(func(_ context.Context, host string) error {
+		checked = append(checked, host)
+		if host == "169.254.169.254" {
+			return errors.New("private address")
+		}
+		return nil
+	})
+
+	_, err := r.Resolve(context.Background(), "http://169.254.169.254/latest/meta-data")
+	assert.ErrorIs(t, err, ErrUnsupportedLink)
+
+	src, err := r.Resolve(context.Background(), "https://example.com:8443/page")
+	require.NoError(t, err)
+	assert.Equal(t, "html", src.Kind())
+
+	// ссылки, узнанные по URL, не опрашиваются и не проверяются
+	_, err = r.Resolve(context.Background(), "https://github.com/foo/bar")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"169.254.169.254", "example.com"}, checked)
+}
+
+func TestGet(t *testing.T) {
+	r := NewRegistry(fakeSource{"github"})
+
+	src, ok := r.Get("github")
+	assert.True(t, ok)
+	assert.Equal(t, "github", src.Kind())
+
+	_, ok = r.Get("feed")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { NewRegistry(fakeSource{"a"}, fakeSource{"a"}) })
+}
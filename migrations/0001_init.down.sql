@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS chats_links;
+DROP TABLE IF EXISTS links;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS chats;
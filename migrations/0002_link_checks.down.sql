@@ -0,0 +1,7 @@
+ALTER TABLE chats_links
+    ALTER COLUMN status DROP NOT NULL,
+    ALTER COLUMN status DROP DEFAULT;
+
+ALTER TABLE links
+    DROP COLUMN state,
+    DROP COLUMN last_checked_at;
@@ -0,0 +1,13 @@
+-- Планировщик хранит время последней проверки и состояние чекера ссылки.
+-- Миграции 0002-0011 пропускают уже существующие столбцы и таблицы: база, созданная
+-- до появления миграций, считается находящейся на версии 1 (см. Migrator.applied
+-- в internal/migrate), хотя часть этих изменений в ней уже может быть
+ALTER TABLE links
+    ADD COLUMN IF NOT EXISTS last_checked_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS state JSONB;
+
+UPDATE chats_links SET status = 'active' WHERE status IS NULL;
+
+ALTER TABLE chats_links
+    ALTER COLUMN status SET DEFAULT 'active',
+    ALTER COLUMN status SET NOT NULL;
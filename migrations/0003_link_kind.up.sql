@@ -0,0 +1,2 @@
+-- источник, который проверяет ссылку; пустой - определяется по ссылке при проверке
+ALTER TABLE links ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- CSS-селектор отслеживаемой части страницы, пустой - страница целиком
+ALTER TABLE links ADD COLUMN IF NOT EXISTS selector TEXT NOT NULL DEFAULT '';
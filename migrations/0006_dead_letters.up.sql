@@ -0,0 +1,10 @@
+-- уведомления, которые не удалось отправить за отведённое число попыток
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    link_id INTEGER REFERENCES links(id) ON DELETE SET NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1 @@
+DROP TABLE link_state;
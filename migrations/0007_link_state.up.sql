@@ -0,0 +1,8 @@
+-- валидаторы HTTP-кэша (ETag, Last-Modified) по каждому URL, который запрашивает чекер ссылки
+CREATE TABLE IF NOT EXISTS link_state (
+    link_id INTEGER REFERENCES links(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    etag TEXT NOT NULL DEFAULT '',
+    last_modified TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (link_id, url)
+);
@@ -0,0 +1,2 @@
+-- интервал проверки в секундах, NULL - интервал планировщика по умолчанию
+ALTER TABLE chats_links ADD COLUMN IF NOT EXISTS check_interval INTEGER CHECK (check_interval > 0);
@@ -0,0 +1,18 @@
+-- история найденных событий по ссылкам
+CREATE TABLE IF NOT EXISTS updates (
+    id BIGSERIAL PRIMARY KEY,
+    link_id INTEGER NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    type VARCHAR(30) NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    author TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    preview TEXT NOT NULL DEFAULT '',
+    -- событие целиком, как его вернул чекер
+    payload JSONB NOT NULL,
+    -- время события в источнике
+    created_at TIMESTAMPTZ NOT NULL,
+    -- когда событие нашёл планировщик
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS updates_link_idx ON updates (link_id, id);
@@ -0,0 +1 @@
+ALTER TABLE chats_links DROP COLUMN filters;
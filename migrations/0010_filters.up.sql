@@ -0,0 +1,2 @@
+-- фильтры событий вида [-]kind:value, см. пакет filters
+ALTER TABLE chats_links ADD COLUMN IF NOT EXISTS filters TEXT[] NOT NULL DEFAULT '{}';
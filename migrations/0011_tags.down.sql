@@ -0,0 +1,13 @@
+-- Прежний столбец допускал один тег из фиксированного списка,
+-- остальные теги при откате теряются
+ALTER TABLE links ADD COLUMN tag VARCHAR(10) CHECK (tag IN ('work', 'hobby', 'family'));
+
+UPDATE links l SET tag = (
+    SELECT min(t.name)
+    FROM links_tags lt
+    JOIN tags t ON t.id = lt.tag_id
+    WHERE lt.link_id = l.id AND t.name IN ('work', 'hobby', 'family')
+);
+
+DROP TABLE links_tags;
+DROP TABLE tags;
@@ -0,0 +1,41 @@
+-- теги чата, которыми он помечает свои ссылки
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    UNIQUE (chat_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS links_tags (
+    chat_id INTEGER NOT NULL,
+    link_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (chat_id, link_id, tag_id),
+    FOREIGN KEY (chat_id, link_id) REFERENCES chats_links(chat_id, link_id) ON DELETE CASCADE
+);
+
+-- Фиксированный тег ссылки становится тегом каждого чата, который на неё подписан
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'links' AND column_name = 'tag'
+    ) THEN
+        INSERT INTO tags (chat_id, name)
+        SELECT DISTINCT cl.chat_id, l.tag
+        FROM chats_links cl
+        JOIN links l ON l.id = cl.link_id
+        WHERE l.tag IS NOT NULL
+        ON CONFLICT (chat_id, name) DO NOTHING;
+
+        INSERT INTO links_tags (chat_id, link_id, tag_id)
+        SELECT cl.chat_id, cl.link_id, t.id
+        FROM chats_links cl
+        JOIN links l ON l.id = cl.link_id
+        JOIN tags t ON t.chat_id = cl.chat_id AND t.name = l.tag
+        ON CONFLICT DO NOTHING;
+
+        ALTER TABLE links DROP COLUMN tag;
+    END IF;
+END
+$$;
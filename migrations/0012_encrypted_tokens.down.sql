@@ -0,0 +1,13 @@
+-- Зашифрованные токены без ключа восстановить нельзя, они удаляются
+DELETE FROM tokens WHERE token IS NULL;
+
+DROP INDEX tokens_chat_idx;
+
+ALTER TABLE tokens
+    DROP CONSTRAINT tokens_value,
+    ALTER COLUMN token SET NOT NULL,
+    DROP COLUMN created_at,
+    DROP COLUMN secret,
+    DROP COLUMN key_id,
+    DROP COLUMN name,
+    DROP COLUMN chat_id;
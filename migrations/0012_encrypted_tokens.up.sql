@@ -0,0 +1,14 @@
+-- Токены принадлежат чату и хранятся зашифрованными AES-GCM.
+-- token остаётся только для старых записей в открытом виде:
+-- сервис шифрует их при запуске и очищает столбец
+ALTER TABLE tokens
+    ADD COLUMN chat_id INTEGER REFERENCES chats(id) ON DELETE CASCADE,
+    ADD COLUMN name VARCHAR(64) NOT NULL DEFAULT '',
+    -- отпечаток ключа, которым зашифрован secret
+    ADD COLUMN key_id VARCHAR(16),
+    ADD COLUMN secret BYTEA,
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ALTER COLUMN token DROP NOT NULL,
+    ADD CONSTRAINT tokens_value CHECK (token IS NOT NULL OR (key_id IS NOT NULL AND secret IS NOT NULL));
+
+CREATE INDEX tokens_chat_idx ON tokens (chat_id);
@@ -0,0 +1,6 @@
+-- Слитые строки обратно не разделяются: каждый чат остаётся подписан на общую ссылку
+ALTER TABLE links
+    DROP CONSTRAINT links_token_id_fkey,
+    ADD CONSTRAINT links_token_id_fkey FOREIGN KEY (token_id) REFERENCES tokens(id) ON DELETE SET NULL;
+
+DROP INDEX links_resource_idx;
@@ -0,0 +1,35 @@
+-- Одинаковые ссылки разных чатов хранятся одной строкой links и проверяются один раз,
+-- чаты подписываются на неё через chats_links.
+-- Существующие дубликаты сливаются в строку с наименьшим id
+CREATE TEMPORARY TABLE links_merge ON COMMIT DROP AS
+SELECT id, keep_id FROM (
+    SELECT id, min(id) OVER (PARTITION BY link, kind, selector, COALESCE(token_id, 0)) AS keep_id
+    FROM links
+) l
+WHERE id <> keep_id;
+
+INSERT INTO chats_links (chat_id, link_id, status, check_interval, filters)
+SELECT cl.chat_id, m.keep_id, cl.status, cl.check_interval, cl.filters
+FROM chats_links cl
+JOIN links_merge m ON m.id = cl.link_id
+ON CONFLICT (chat_id, link_id) DO NOTHING;
+
+INSERT INTO links_tags (chat_id, link_id, tag_id)
+SELECT lt.chat_id, m.keep_id, lt.tag_id
+FROM links_tags lt
+JOIN links_merge m ON m.id = lt.link_id
+ON CONFLICT DO NOTHING;
+
+UPDATE updates u SET link_id = m.keep_id FROM links_merge m WHERE u.link_id = m.id;
+UPDATE outbox o SET link_id = m.keep_id FROM links_merge m WHERE o.link_id = m.id;
+UPDATE dead_letters d SET link_id = m.keep_id FROM links_merge m WHERE d.link_id = m.id;
+
+DELETE FROM links l USING links_merge m WHERE l.id = m.id;
+
+CREATE UNIQUE INDEX links_resource_idx ON links (link, kind, selector, COALESCE(token_id, 0));
+
+-- Обнуление token_id могло бы столкнуть ссылку с такой же анонимной,
+-- поэтому токен, который используют ссылки, удалить нельзя
+ALTER TABLE links
+    DROP CONSTRAINT links_token_id_fkey,
+    ADD CONSTRAINT links_token_id_fkey FOREIGN KEY (token_id) REFERENCES tokens(id);
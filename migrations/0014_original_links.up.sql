@@ -0,0 +1,6 @@
+-- links.link хранит каноническую ссылку, а original_link - ссылку в том виде,
+-- в каком её добавил чат. У ссылок, добавленных до канонизации, это прежнее
+-- значение links.link. Сами ссылки приводит к каноническому виду сервис при запуске
+ALTER TABLE chats_links ADD COLUMN original_link TEXT;
+
+UPDATE chats_links cl SET original_link = l.link FROM links l WHERE l.id = cl.link_id;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS outbox_next_attempt_idx;
+ALTER TABLE outbox ADD COLUMN sent_at TIMESTAMPTZ;
+CREATE INDEX outbox_pending_idx ON outbox (next_attempt_at) WHERE sent_at IS NULL;
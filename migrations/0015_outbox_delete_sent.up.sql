@@ -0,0 +1,6 @@
+-- Отправленное уведомление удаляется из outbox, а не копится в нём с sent_at
+DELETE FROM outbox WHERE sent_at IS NOT NULL;
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+ALTER TABLE outbox DROP COLUMN sent_at;
+CREATE INDEX outbox_next_attempt_idx ON outbox (next_attempt_at);